@@ -0,0 +1,102 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/gotomicro/ego/core/elog"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// accessLogRoute 路由日志级别规则，pattern 语法同 routeMatcher
+type accessLogRoute struct {
+	pattern string
+	level   string
+}
+
+// accessLogPolicy 访问日志策略，决定请求是否记录访问日志以及日志级别
+type accessLogPolicy struct {
+	excludes routeMatcher
+	levels   []accessLogRoute
+}
+
+// newAccessLogPolicy 根据配置构建访问日志策略
+func newAccessLogPolicy(config *Config) (*accessLogPolicy, error) {
+	excludes, err := newRouteMatcher("AccessLogExcludeRoutes", config.AccessLogExcludeRoutes)
+	if err != nil {
+		return nil, err
+	}
+	p := &accessLogPolicy{excludes: excludes}
+	for pattern, level := range config.AccessLogRouteLevels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid AccessLogRouteLevels %q, %w", pattern, err)
+		}
+		level = strings.ToLower(level)
+		switch level {
+		case "debug", "info", "warn", "error":
+		default:
+			return nil, fmt.Errorf("invalid AccessLogRouteLevels %q, unknown level %q", pattern, level)
+		}
+		p.levels = append(p.levels, accessLogRoute{pattern: pattern, level: level})
+	}
+	// 规则越长越具体，优先匹配
+	sort.Slice(p.levels, func(i, j int) bool {
+		if len(p.levels[i].pattern) != len(p.levels[j].pattern) {
+			return len(p.levels[i].pattern) > len(p.levels[j].pattern)
+		}
+		return p.levels[i].pattern < p.levels[j].pattern
+	})
+	return p, nil
+}
+
+// Excluded 路由是否不记录访问日志
+func (p *accessLogPolicy) Excluded(method, routePath string) bool {
+	if p == nil {
+		return false
+	}
+	return p.excludes.Match(method, routePath)
+}
+
+// Level 返回路由的访问日志级别，默认 info
+func (p *accessLogPolicy) Level(method, routePath string) string {
+	if p != nil {
+		for _, route := range p.levels {
+			if matchRoute(route.pattern, method, routePath) {
+				return route.level
+			}
+		}
+	}
+	return "info"
+}
+
+// sampled 按采样率判断是否记录，rate 大于等于1时全部记录
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return rand.Float64() < rate
+}
+
+// isAccessError 请求是否出错，出错的请求不参与采样，始终记录
+func isAccessError(ctx Context) bool {
+	return ctx.Error() != nil || ctx.StatusCode() >= http.StatusInternalServerError
+}
+
+// logAccess 按级别输出访问日志
+func logAccess(logger *elog.Component, level string, msg string, fields ...elog.Field) {
+	switch level {
+	case "debug":
+		logger.Debug(msg, fields...)
+	case "warn":
+		logger.Warn(msg, fields...)
+	case "error":
+		logger.Error(msg, fields...)
+	default:
+		logger.Info(msg, fields...)
+	}
+}
@@ -0,0 +1,98 @@
+package eref
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// accessLogServer 只注册错误恢复中间件的容器，返回记录到的日志
+func accessLogServer(t *testing.T, config *Config) (*restful.Container, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	policy, err := newAccessLogPolicy(config)
+	if err != nil {
+		t.Fatalf("newAccessLogPolicy: %v", err)
+	}
+	config.accessLogPolicy = policy
+	if config.redactor, err = newRedactor(config); err != nil {
+		t.Fatalf("newRedactor: %v", err)
+	}
+	container := restful.NewContainer()
+	container.Filter(recoverMiddleware(elog.DefaultContainer().Build(elog.WithZapCore(core)), config))
+	ws := new(restful.WebService).Path("/api")
+	for _, path := range []string{"/ping", "/health", "/debug"} {
+		ws.Route(ws.GET(path).To(RouteContext(func(ctx Context) {})))
+	}
+	ws.Route(ws.GET("/fail").To(RouteContext(func(ctx Context) {
+		ctx.WriteHeader(http.StatusInternalServerError)
+	})))
+	container.Add(ws)
+	return container, logs
+}
+
+// accessLevels 请求路径，返回访问日志级别，没有记录时为空
+func accessLevels(container *restful.Container, logs *observer.ObservedLogs, target string) string {
+	logs.TakeAll()
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	entries := logs.FilterMessage("access").TakeAll()
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[0].Level.String()
+}
+
+func TestAccessLogRouteLevels(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessLogExcludeRoutes = []string{"/api/health", "/api/fail"}
+	config.AccessLogRouteLevels = map[string]string{
+		"/api/*":        "warn",
+		"GET./api/ping": "error",
+		"/api/debug":    "DEBUG",
+	}
+	container, logs := accessLogServer(t, config)
+
+	for target, want := range map[string]string{
+		"/api/ping":   "error", // 更具体的规则优先
+		"/api/debug":  "debug",
+		"/api/health": "",
+		"/api/fail":   "warn", // 出错的请求不受排除规则影响
+	} {
+		if got := accessLevels(container, logs, target); got != want {
+			t.Errorf("%s access log level = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessLogSampleRate = 0
+	config.AccessLogRouteLevels = map[string]string{"/api/fail": "debug"}
+	container, logs := accessLogServer(t, config)
+
+	if got := accessLevels(container, logs, "/api/ping"); got != "" {
+		t.Errorf("sampled out request logged at %q", got)
+	}
+	// 出错的请求始终记录，debug 级别提升为 info
+	if got := accessLevels(container, logs, "/api/fail"); got != "info" {
+		t.Errorf("failed request access log level = %q, want info", got)
+	}
+}
+
+func TestAccessLogPolicyInvalidLevel(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessLogRouteLevels = map[string]string{"/api/*": "fatal"}
+	if _, err := newAccessLogPolicy(config); err == nil {
+		t.Error("newAccessLogPolicy with unknown level succeeded")
+	}
+}
+
+func TestSampled(t *testing.T) {
+	if !sampled(1) || sampled(0) || sampled(-1) {
+		t.Error("sampled boundaries")
+	}
+}
@@ -0,0 +1,177 @@
+package eref
+
+import (
+	"errors"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"strings"
+)
+
+// credentialSource 凭证的读取位置
+type credentialSource struct {
+	from string // header、cookie、query
+	name string
+}
+
+// parseCredentialLookup 解析凭证读取位置，格式为 逗号分隔的 来源:名称，如 header:Authorization,cookie:token,query:token
+func parseCredentialLookup(name, lookup string) ([]credentialSource, error) {
+	var sources []credentialSource
+	for _, item := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid %s %q", name, item)
+		}
+		switch parts[0] {
+		case "header", "cookie", "query":
+		default:
+			return nil, fmt.Errorf("invalid %s %q, unknown source %q", name, item, parts[0])
+		}
+		sources = append(sources, credentialSource{from: parts[0], name: parts[1]})
+	}
+	return sources, nil
+}
+
+// readCredential 按顺序读取凭证，header中的 Bearer 前缀会被去掉
+func readCredential(req *http.Request, sources []credentialSource) string {
+	for _, source := range sources {
+		var value string
+		switch source.from {
+		case "header":
+			value = strings.TrimSpace(req.Header.Get(source.name))
+			if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+				value = strings.TrimSpace(value[7:])
+			}
+		case "cookie":
+			if cookie, err := req.Cookie(source.name); err == nil {
+				value = cookie.Value
+			}
+		case "query":
+			value = req.URL.Query().Get(source.name)
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// 鉴权方式
+const (
+	IdentityJWT    = "jwt"
+	IdentityAPIKey = "apikey"
+	IdentityMTLS   = "mtls"
+)
+
+// identityAttribute 调用方身份在 restful.Request 中的属性名
+const identityAttribute = "identity"
+
+// Identity 鉴权通过的调用方身份，由鉴权中间件写入，授权中间件根据身份判断是否可以访问路由
+type Identity struct {
+	Type    string   // 鉴权方式，jwt、apikey、mtls
+	Subject string   // 调用方标识，jwt为用户ID，apikey为 APIKey.ID，mtls为 SPIFFE ID 或证书CN
+	Roles   []string // 角色
+	Scopes  []string // 授权范围
+}
+
+// Identity 调用方身份，未经过鉴权时返回false
+func (c Context) Identity() (Identity, bool) {
+	identity, ok := c.Request.Attribute(identityAttribute).(Identity)
+	return identity, ok
+}
+
+// setIdentity 记录调用方身份
+func (c Context) setIdentity(identity Identity) {
+	c.SetAttribute(identityAttribute, identity)
+}
+
+// skipAuth 路由不需要鉴权，命中排除规则或者声明为 Public
+func skipAuth(ctx Context, excludes routeMatcher) bool {
+	if excludes.Match(ctx.Req().Method, ctx.SelectedRoutePath()) {
+		return true
+	}
+	if route := ctx.SelectedRoute(); route != nil {
+		public, _ := route.Metadata()[MetadataPublic].(bool)
+		return public
+	}
+	return false
+}
+
+// 多种鉴权方式的组合方式
+const (
+	AuthModeAll = "all" // 每种开启的鉴权方式都需要通过
+	AuthModeAny = "any" // 按 JWT、API key、签名的顺序尝试，任意一个通过即可
+)
+
+// errMissingCredential 请求没有携带该鉴权方式的凭证
+var errMissingCredential = errors.New("missing credential")
+
+// authError 鉴权失败
+type authError struct {
+	status    int    // 响应状态码
+	challenge string // WWW-Authenticate 响应头，为空时不返回
+	err       error
+}
+
+// newAuthError 新建鉴权失败
+func newAuthError(status int, err error) *authError {
+	return &authError{status: status, err: err}
+}
+
+// authenticator 凭证鉴权，JWT、API key、签名鉴权实现
+type authenticator interface {
+	// skip 路由不需要该方式鉴权
+	skip(ctx Context) bool
+	// authenticate 校验请求携带的凭证，通过后写入调用方身份
+	authenticate(ctx *Context) *authError
+}
+
+// authMiddleware 单个鉴权方式的中间件，AuthMode 为 all 时每种鉴权方式各自添加
+func authMiddleware(a authenticator) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		if a.skip(ctx.Context) {
+			ctx.ProcessFilter()
+			return
+		}
+		if err := a.authenticate(&ctx.Context); err != nil {
+			writeAuthError(ctx.Context, err)
+			return
+		}
+		ctx.ProcessFilter()
+	})
+}
+
+// anyAuthMiddleware AuthMode 为 any 时的鉴权中间件，按顺序尝试，使用第一个通过的鉴权方式的身份
+// 只尝试没有排除该路由的鉴权方式，全部鉴权方式都排除了该路由或者路由声明为 Public 时直接放行
+func anyAuthMiddleware(auths []authenticator) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		var failed *authError
+		for _, a := range auths {
+			if a.skip(ctx.Context) {
+				continue
+			}
+			err := a.authenticate(&ctx.Context)
+			if err == nil {
+				ctx.ProcessFilter()
+				return
+			}
+			// 优先返回携带了凭证的鉴权方式的错误
+			if failed == nil || errors.Is(failed.err, errMissingCredential) && !errors.Is(err.err, errMissingCredential) {
+				failed = err
+			}
+		}
+		if failed == nil {
+			ctx.ProcessFilter()
+			return
+		}
+		writeAuthError(ctx.Context, failed)
+	})
+}
+
+// writeAuthError 返回鉴权失败的响应
+func writeAuthError(ctx Context, err *authError) {
+	if err.challenge != "" {
+		ctx.Response.AddHeader(headerWWWAuthenticate, err.challenge)
+	}
+	_ = ctx.WriteErrorString(err.status, http.StatusText(err.status))
+}
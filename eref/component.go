@@ -2,21 +2,29 @@ package eref
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/gotomicro/ego/core/constant"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/gotomicro/ego/server"
+	"github.com/quic-go/quic-go/http3"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // PackageName 包名
 const PackageName = "server.eref"
 
-var comp *Component
+// comp 最后构建的组件，只用于包级别的 websocket 函数，请求处理时通过 componentAttribute 获取组件
+var comp atomic.Pointer[Component]
+
+// componentAttribute 组件在 restful.Request 中的属性名
+const componentAttribute = "eref.component"
 
 // Component 构件
 type Component struct {
@@ -25,25 +33,42 @@ type Component struct {
 	config *Config         // 配置
 	logger *elog.Component // 日记
 
-	Server           *http.Server      // HTTP 服务
-	listener         net.Listener      // 网络地址
-	routerCommentMap map[string]string // router 的中文注释，非并发安全
+	Server      *http.Server       // HTTP 服务
+	container   *restful.Container // restful 容器
+	listener    net.Listener       // 网络地址，Address 对应的listener
+	listeners   []net.Listener     // 全部的listener
+	http3Conn   net.PacketConn     // HTTP/3 的UDP连接，未开启 EnableHTTP3 时为nil
+	http3Server *http3.Server      // HTTP/3 服务
+	tlsConfig   *tls.Config        // TLS配置，未开启 EnableTLS 时为nil
+	routes      *routeRegistry     // 路由注册表
+	health      *healthChecker     // 健康检查
+	tracker     *connTracker       // 正在处理的请求和劫持的连接
+	stopped     bool               // 是否已经调用 Stop、GracefulStop
+	streamsDone chan struct{}      // Stop、GracefulStop 时关闭，通知 SSE 等长连接结束
+	inherited   bool               // listener 是否由平滑重启的父进程传递
 }
 
 // newComponent 新建一个构件
 func newComponent(name string, config *Config, logger *elog.Component) *Component {
-	comp = &Component{
-		name:             name,
-		config:           config,
-		logger:           logger,
-		listener:         nil,
-		routerCommentMap: make(map[string]string),
+	c := &Component{
+		name:        name,
+		config:      config,
+		logger:      logger,
+		container:   config.container,
+		listener:    nil,
+		routes:      newRouteRegistry(),
+		health:      newHealthChecker(config),
+		tracker:     newConnTracker(),
+		streamsDone: make(chan struct{}),
 	}
 
 	// 注册解析类型
 	restful.RegisterEntityAccessor(MIME_MSGPACK, NewEntityAccessorMsgPack())
 	restful.RegisterEntityAccessor(restful.MIME_JSON, NewEntityAccessorJson())
-	return comp
+	if !config.isolated {
+		comp.Store(c)
+	}
+	return c
 }
 
 // Name 配置名称
@@ -59,45 +84,168 @@ func (c *Component) PackageName() string {
 // Init 初始化
 func (c *Component) Init() error {
 	var err error
-	c.listener, err = net.Listen("tcp", c.config.Address())
+	c.listeners, err = c.listenAll()
 	if err != nil {
 		c.logger.Panic("new eref server err", elog.FieldErrKind("listen err"), elog.FieldErr(err))
 	}
+	c.listener = c.listeners[0]
+	if c.config.EnableGracefulRestart {
+		for _, l := range c.listeners {
+			registerRestartListener(l, c.logger)
+		}
+	}
 	c.config.Port = c.listener.Addr().(*net.TCPAddr).Port
+	if c.config.EnableTLS {
+		c.tlsConfig, err = newTLSConfig(c.config)
+		if err != nil {
+			c.logger.Panic("new eref server err", elog.FieldErrKind("tls err"), elog.FieldErr(err))
+		}
+	}
+	if c.config.EnableHTTP3 {
+		c.http3Conn, err = c.listenHTTP3()
+		if err != nil {
+			c.logger.Panic("new eref server err", elog.FieldErrKind("http3 listen err"), elog.FieldErr(err))
+		}
+		if c.config.EnableGracefulRestart {
+			registerRestartPacketConn(c.http3Conn, c.logger)
+		}
+	}
 	return nil
 }
 
-// RegisterRouteComment 注册路由注释
+// componentFilter 把组件写入请求属性，需要作为第一个中间件，后续的中间件和 handler 通过 requestComponent 获取
+func (c *Component) componentFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	req.SetAttribute(componentAttribute, c)
+	// 中间件中开启、没有关闭的 SSE 流在请求结束时关闭
+	defer closeSSEStream(req)
+	chain.ProcessFilter(req, resp)
+}
+
+// requestComponent 处理请求的组件，没有经过 componentFilter 时返回nil
+func requestComponent(req *restful.Request) *Component {
+	c, _ := req.Attribute(componentAttribute).(*Component)
+	return c
+}
+
+// Container 组件使用的 restful 容器
+func (c *Component) Container() *restful.Container {
+	return c.container
+}
+
+// Add 注册 WebService 到组件的容器
+func (c *Component) Add(services ...*restful.WebService) {
+	for _, ws := range services {
+		c.container.Add(ws)
+	}
+}
+
+// Handle 注册不经过中间件的 http.Handler，例如健康检查、文档
+func (c *Component) Handle(pattern string, handler http.HandlerFunc) {
+	c.container.ServeMux.Handle(pattern, handler)
+}
+
+// handleBuiltin 注册内置的 http.Handler，例如健康检查、文档
+// 多个组件共享 restful.DefaultContainer 或者业务已经注册了同样的路径时跳过，避免 ServeMux panic
+func (c *Component) handleBuiltin(pattern string, handler http.HandlerFunc) {
+	if _, registered := c.container.ServeMux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: pattern}}); registered == pattern {
+		c.logger.Warn("handler already registered, skip", elog.String("pattern", pattern))
+		return
+	}
+	c.Handle(pattern, handler)
+}
+
+// metadataBuiltin 内置接口的路由元数据，OpenAPI 文档不包含内置接口
+const metadataBuiltin = "eref.builtin"
+
+// addBuiltinRoute 注册内置的 GET 接口为 restful 路由，和业务路由一样经过IP访问控制、鉴权、限流等中间件
+// 容器中已经存在同样根路径的 WebService 时跳过，避免 restful 重复注册时退出进程
+func (c *Component) addBuiltinRoute(path string, handler http.HandlerFunc) {
+	for _, ws := range c.container.RegisteredWebServices() {
+		if ws.RootPath() == path {
+			c.logger.Warn("web service already registered, skip", elog.String("path", path))
+			return
+		}
+	}
+	ws := new(restful.WebService).Path(path).Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("").Metadata(metadataBuiltin, true).To(func(req *restful.Request, resp *restful.Response) {
+		handler(resp, req.Request)
+	}))
+	c.container.Add(ws)
+}
+
+// Handler 组件的 http.Handler，记录正在处理的请求，可以直接用于 httptest
+func (c *Component) Handler() http.Handler {
+	return c.tracker.handler(c.container)
+}
+
+// RegisterRouteComment 注册路由注释，并发安全
 func (c *Component) RegisterRouteComment(method, path, comment string) {
-	c.routerCommentMap[commentUniqKey(method, path)] = comment
+	c.routes.setComment(method, path, comment)
 }
 
 // Start implements server.Component interface.
 func (c *Component) Start() error {
-	for _, ws := range restful.DefaultContainer.RegisteredWebServices() {
-		for _, route := range ws.Routes() {
-			info, flag := c.routerCommentMap[commentUniqKey(route.Method, route.Path)]
-			// 如果有注释，日志打出来
-			if flag {
-				c.logger.Info("add route", elog.FieldMethod(route.Method), elog.String("path", route.Path), elog.Any("info", info))
-			} else {
-				c.logger.Info("add route", elog.FieldMethod(route.Method), elog.String("path", route.Path))
-			}
+	for _, route := range c.Routes() {
+		// 如果有注释，日志打出来
+		if route.Comment != "" {
+			c.logger.Info("add route", elog.FieldMethod(route.Method), elog.String("path", route.Path), elog.Any("info", route.Comment))
+		} else {
+			c.logger.Info("add route", elog.FieldMethod(route.Method), elog.String("path", route.Path))
 		}
 	}
 	// 因为start和stop在多个goroutine里，需要对Server上写锁
 	c.mu.Lock()
+	// Start 之前已经调用了 Stop
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	handler := c.Handler()
+	if c.http3Conn != nil {
+		c.http3Server = c.newHTTP3Server()
+		handler = altSvcHandler(c.http3Server, handler)
+	}
 	c.Server = &http.Server{
 		Addr:              c.config.Address(),
-		Handler:           restful.DefaultContainer,
+		Handler:           handler,
 		ReadHeaderTimeout: c.config.ServerReadHeaderTimeout,
 		ReadTimeout:       c.config.ServerReadTimeout,
 		WriteTimeout:      c.config.ServerWriteTimeout,
+		ConnState:         c.tracker.connState,
 	}
+	srv, h3 := c.Server, c.http3Server
 	c.mu.Unlock()
-	err := c.Server.Serve(c.listener)
-	if err == http.ErrServerClosed {
-		return nil
+	// 平滑重启的新进程已经可以接收请求，通知父进程优雅退出
+	if c.inherited {
+		notifyParent(c.logger)
+	}
+	serving := len(c.listeners)
+	errs := make(chan error, serving+1)
+	if h3 != nil {
+		serving++
+		go func() {
+			errs <- h3.Serve(c.http3Conn)
+		}()
+	}
+	for _, l := range c.listeners {
+		listener := c.tracker.listener(l)
+		if c.tlsConfig != nil {
+			listener = tls.NewListener(listener, c.tlsConfig)
+		}
+		go func() {
+			errs <- srv.Serve(listener)
+		}()
+	}
+	var err error
+	for i := 0; i < serving; i++ {
+		// 任意一个listener出错时关闭服务
+		if serveErr := <-errs; serveErr != http.ErrServerClosed && err == nil {
+			err = serveErr
+			_ = srv.Close()
+			if h3 != nil {
+				_ = h3.Close()
+			}
+		}
 	}
 	return err
 }
@@ -105,36 +253,99 @@ func (c *Component) Start() error {
 // Stop implements server.Component interface
 // it will terminate go-restful server immediately
 func (c *Component) Stop() error {
-	c.mu.Lock()
-	err := c.Server.Close()
-	c.mu.Unlock()
+	c.health.setShuttingDown()
+	srv, h3 := c.stop()
+	if srv == nil {
+		return nil
+	}
+	err := srv.Close()
+	if h3 != nil {
+		_ = h3.Close()
+	}
+	c.tracker.closeHijacked()
 	return err
 }
 
 // GracefulStop implements server.Component interface
 // it will stop go-restful server gracefully
 func (c *Component) GracefulStop(ctx context.Context) error {
-	c.mu.Lock()
-	err := c.Server.Shutdown(ctx)
-	c.mu.Unlock()
+	// readiness 先失败，等待负载均衡摘除流量后再关闭
+	c.health.setShuttingDown()
+	if delay := c.config.ShutdownDrainDelay; delay > 0 {
+		c.logger.Info("shutdown drain delay", elog.Duration("delay", delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	srv, h3 := c.stop()
+	if srv == nil {
+		return nil
+	}
+	// HTTP/3 发送 GOAWAY 后等待客户端关闭连接，到期时直接关闭
+	h3Done := make(chan struct{})
+	go func() {
+		defer close(h3Done)
+		if h3 != nil {
+			_ = h3.Shutdown(ctx)
+		}
+	}()
+	err := srv.Shutdown(ctx)
+	<-h3Done
+	// Shutdown 不会等待被劫持的连接
+	if c.tracker.wait(ctx.Done()) {
+		return err
+	}
+	c.tracker.logRemaining(c.logger)
+	_ = srv.Close()
+	c.tracker.closeHijacked()
+	if err == nil {
+		err = ctx.Err()
+	}
 	return err
 }
 
+// stop 标记服务已关闭，返回正在运行的 Server，Start 之前调用时关闭 listener 并返回nil
+func (c *Component) stop() (*http.Server, *http3.Server) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stopped {
+		close(c.streamsDone)
+	}
+	c.stopped = true
+	if c.Server == nil {
+		for _, l := range c.listeners {
+			_ = l.Close()
+		}
+		if c.http3Conn != nil {
+			_ = c.http3Conn.Close()
+		}
+	}
+	return c.Server, c.http3Server
+}
+
 // Info returns server info, used by governor and consumer balancer
 func (c *Component) Info() *server.ServiceInfo {
-	info := server.ApplyOptions(
-		server.WithScheme("http"),
+	scheme := "http"
+	if c.tlsConfig != nil {
+		scheme = "https"
+	}
+	options := []server.Option{
+		server.WithScheme(scheme),
 		server.WithAddress(c.listener.Addr().String()),
 		server.WithKind(constant.ServiceProvider),
-	)
+	}
+	// 监听多个地址时，全部地址写入元数据
+	if addresses := c.listenAddresses(); len(addresses) > 1 {
+		options = append(options, server.WithMetaData("addresses", strings.Join(addresses, ",")))
+	}
+	info := server.ApplyOptions(options...)
 	return &info
 }
 
-func commentUniqKey(method, path string) string {
-	return fmt.Sprintf("%s@%s", strings.ToLower(method), path)
-}
-
-// Listener listener信息
+// Listener Address 对应的listener信息，全部listener见 Listeners
 func (c *Component) Listener() net.Listener {
 	return c.listener
 }
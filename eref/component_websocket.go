@@ -6,21 +6,27 @@ import (
 	"net/http"
 )
 
-// BuildWebsocket ..
+// BuildWebsocket 使用最后构建的组件的配置，多个组件时使用 Component.BuildWebsocket
 func BuildWebsocket(opts ...WebSocketOption) *WebSocket {
-	return comp.BuildWebsocket(opts...)
+	c := comp.Load()
+	if c == nil {
+		c = &Component{config: DefaultConfig()}
+	}
+	return c.BuildWebsocket(opts...)
 }
 
 // UpgradeFilter protocol to WebSocket
 func UpgradeFilter(ws *WebSocket, handler WebSocketFunc) restful.FilterFunction {
 	return Filter(func(ctx FilterContext) {
-		comp.UpgradeRoute(ws, handler)
+		ws.Upgrade(ctx.Resp(), ctx.Req(), ctx.Context, handler)
 	})
 }
 
 // UpgradeRoute protocol to WebSocket
 func UpgradeRoute(ws *WebSocket, handler WebSocketFunc) restful.RouteFunction {
-	return comp.UpgradeRoute(ws, handler)
+	return RouteContext(func(ctx Context) {
+		ws.Upgrade(ctx.Resp(), ctx.Req(), ctx, handler)
+	})
 }
 
 // UpgradeRoute protocol to WebSocket
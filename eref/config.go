@@ -2,6 +2,8 @@ package eref
 
 import (
 	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/google/cel-go/cel"
 	"github.com/gotomicro/ego/core/eflag"
 	"github.com/gotomicro/ego/core/util/xtime"
 	"sync"
@@ -10,27 +12,141 @@ import (
 
 // Config HTTP config
 type Config struct {
-	Host                       string // IP地址，默认0.0.0.0
-	Port                       int    // PORT端口，默认9001
-	Network                    string
-	ServerReadTimeout          time.Duration // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
-	ServerReadHeaderTimeout    time.Duration // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
-	ServerWriteTimeout         time.Duration // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
-	ContextTimeout             time.Duration // 只能用于IO操作，才能触发，默认不启用
-	EnableMetricInterceptor    bool          // 是否开启监控，默认开启
-	EnableTraceInterceptor     bool          // 是否开启链路追踪，默认开启
-	EnableLocalMainIP          bool          // 自动获取ip地址
-	EnableGzip                 bool          //  开启gzip 压缩
-	SlowLogThreshold           time.Duration // 服务慢日志，默认500ms
-	EnableAccessInterceptor    bool          // 是否开启，记录请求数据
-	EnableAccessInterceptorReq bool          // 是否开启记录请求参数，默认不开启
-	EnableAccessInterceptorRes bool          // 是否开启记录响应参数，默认不开启
-	WebsocketHandshakeTimeout  time.Duration // 握手时间
-	WebsocketReadBufferSize    int           // WebsocketReadBufferSize
-	WebsocketWriteBufferSize   int           // WebsocketWriteBufferSize
-	EnableWebsocketCompression bool          // 是否开通压缩
-	EnableWebsocketCheckOrigin bool          // 是否支持跨域
-	mu                         sync.RWMutex  // mutex for EnableAccessInterceptorReq、EnableAccessInterceptorRes、AccessInterceptorReqResFilter、aiReqResCelPrg
+	Host                            string // IP地址，默认0.0.0.0
+	Port                            int    // PORT端口，默认9001
+	Network                         string
+	ExtraAddresses                  []string             // 额外的监听地址，如 [::1]:9090、127.0.0.1:9091，和 Address 使用同一个 Server
+	EnableReusePort                 bool                 // 是否开启 SO_REUSEPORT，多个listener或者多个进程可以监听同一个端口，由内核分配连接
+	ReusePortListeners              int                  // 开启 EnableReusePort 时每个地址的listener数量，默认1
+	ServerReadTimeout               time.Duration        // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
+	ServerReadHeaderTimeout         time.Duration        // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
+	ServerWriteTimeout              time.Duration        // 服务端，用于读取io报文过慢的timeout，通常用于互联网网络收包过慢，如果你的go在最外层，可以使用他，默认不启用。
+	ContextTimeout                  time.Duration        // 只能用于IO操作，才能触发，默认不启用
+	EnableTLS                       bool                 // 是否开启TLS
+	TLSCertFile                     string               // 服务端证书
+	TLSKeyFile                      string               // 服务端私钥
+	TLSClientCAFile                 string               // 校验客户端证书的CA，配置后默认校验客户端传入的证书
+	TLSClientAuth                   string               // 客户端证书策略，request、verify_if_given、require_and_verify
+	EnableHTTP3                     bool                 // 是否同时开启HTTP/3，需要开启 EnableTLS，响应中通过 Alt-Svc 告知客户端
+	HTTP3Address                    string               // HTTP/3监听的UDP地址，默认和 Address 相同
+	TrustedProxies                  []string             // 可信代理的CIDR，只有来自可信代理的请求才会读取 X-Forwarded-For 等header；未配置时信任全部，开启 EnableIPFilter、DocsUIAllowIPs 或者按IP限流时不信任任何代理
+	EnableMetricInterceptor         bool                 // 是否开启监控，默认开启
+	MetricLatencyBuckets            []float64            // 耗时直方图桶，单位秒，默认 prometheus.DefBuckets
+	MetricSizeBuckets               []float64            // 请求响应大小直方图桶，单位字节，默认 64B ~ 1MB
+	EnableTraceInterceptor          bool                 // 是否开启链路追踪，默认开启
+	TraceExcludeRoutes              []string             // 不记录链路的路由，语法同 AccessLogExcludeRoutes
+	EnableOtelTracerProvider        bool                 // 未通过 etrace 注册、直接使用 otel.SetTracerProvider 设置链路追踪时开启
+	EnableLocalMainIP               bool                 // 自动获取ip地址
+	EnableGzip                      bool                 //  开启gzip 压缩
+	GzipLevel                       int                  // gzip 压缩级别1-9，默认 gzip.DefaultCompression，SSE、websocket 不压缩
+	SSEHeartbeatInterval            time.Duration        // SSE 心跳间隔，默认15s，为0时不发送心跳
+	SlowLogThreshold                time.Duration        // 服务慢日志，默认500ms
+	EnableRequestID                 bool                 // 是否开启请求ID，默认开启
+	RequestIDHeader                 string               // 请求ID的header，默认 X-Request-Id
+	EnableAccessInterceptor         bool                 // 是否开启，记录请求数据
+	EnableAccessInterceptorReq      bool                 // 是否开启记录请求参数，默认不开启
+	EnableAccessInterceptorRes      bool                 // 是否开启记录响应参数，默认不开启
+	AccessLogSampleRate             float64              // 访问日志采样率，取值0~1，默认1全部记录，出错和慢请求始终记录
+	AccessLogExcludeRoutes          []string             // 不记录访问日志的路由，path.Match 语法，可带方法，如 GET./healthz，出错和慢请求仍然记录
+	AccessLogRouteLevels            map[string]string    // 路由访问日志级别，key 同 AccessLogExcludeRoutes，value 为 debug、info、warn、error，默认info
+	AccessInterceptorReqResFilter   string               // AccessInterceptorReq 过滤器，只有符合过滤器的请求才会记录 Req 和 Res
+	AccessInterceptorRedactHeaders  []string             // 记录请求响应参数时需要打码的header，Authorization、Proxy-Authorization、Cookie、Set-Cookie、X-Api-Key、X-Signature 以及鉴权读取凭证的header、查询参数始终打码
+	AccessInterceptorRedactFields   []string             // 记录请求响应参数时需要打码的JSON字段，单个字段名匹配任意层级，多级路径用 . 分隔，* 匹配任意key或数组元素
+	AccessInterceptorRedactPatterns []string             // 记录请求响应参数时需要打码的正则，例如卡号、手机号
+	AccessInterceptorRedactMask     string               // 打码掩码，默认 ******
+	EnableCORS                      bool                 // 是否开启跨域，默认不开启
+	CORSAllowOrigins                []string             // 允许跨域的origin，支持 * 全部放行、https://*.example.com 通配以及 ~ 开头的正则
+	CORSAllowMethods                []string             // 允许跨域的方法，默认为路由上注册的方法
+	CORSAllowHeaders                []string             // 允许跨域的header，默认回显预检请求的 Access-Control-Request-Headers
+	CORSExposeHeaders               []string             // 允许浏览器读取的响应header
+	CORSAllowCredentials            bool                 // 是否允许携带凭证
+	CORSMaxAge                      time.Duration        // 预检请求缓存时间
+	EnableRateLimit                 bool                 // 是否开启限流，默认不开启
+	RateLimitKey                    string               // 限流维度，ip、app、route，默认ip，按IP限流时需要配置 TrustedProxies 才读取转发的客户端IP，可以通过 WithRateLimitKeyFunc 自定义
+	RateLimitAlgorithm              string               // 限流算法，token_bucket、sliding_window，默认token_bucket
+	RateLimitRate                   int                  // 全局限流，每个窗口允许的请求数，0表示只按路由限流
+	RateLimitWindow                 time.Duration        // 限流窗口，默认1s
+	RateLimitBurst                  int                  // 令牌桶容量，默认等于 RateLimitRate
+	RateLimitRoutes                 map[string]RateLimit // 路由限流规则，key 语法同 AccessLogExcludeRoutes，未配置的算法、窗口使用全局配置
+	EnableLoadShedding              bool                 // 是否开启过载保护，默认不开启
+	ShedMaxConcurrency              int                  // 全局最大并发，0表示不限制，latency模式下也作为并发上限
+	ShedRouteConcurrency            map[string]int       // 路由最大并发，key 语法同 AccessLogExcludeRoutes
+	ShedAdaptiveMode                string               // 自适应过载保护，cpu(BBR)、latency(Gradient)，默认不开启
+	ShedCPUThreshold                float64              // cpu模式下的CPU阈值，取值0~1，默认0.8，容器中按 cgroup 的CPU配额计算
+	ShedLatencyTolerance            float64              // latency模式下允许短期耗时相对长期耗时增长的倍数，默认2
+	ShedPriorityHeader              string               // 请求优先级header，默认 X-Request-Priority，取值 critical、high、normal、low
+	EnableJWT                       bool                 // 是否开启JWT鉴权，默认不开启
+	JWTIssuers                      []string             // 允许的签发方，为空时不校验
+	JWTAudiences                    []string             // 允许的受众，命中任意一个即可，为空时不校验
+	JWTAlgorithms                   []string             // 允许的签名算法，默认 HS256/384/512、RS256/384/512、ES256/384/512，实际可用的算法还取决于配置的密钥类型
+	JWTSecret                       string               // HMAC密钥
+	JWTPublicKeyFiles               []string             // PEM格式的RSA、ECDSA公钥文件
+	JWKSURL                         string               // JWKS地址，支持 http(s) 地址和本地文件，按kid查找公钥
+	JWKSRefreshInterval             time.Duration        // JWKS缓存时间，默认10m，遇到未知kid时会提前刷新
+	JWTClockSkew                    time.Duration        // 校验 exp、nbf、iat 时允许的时钟偏差
+	JWTAllowMissingExp              bool                 // 是否允许不带 exp 的token，默认不允许，没有过期时间的token泄露后永久有效
+	JWTTokenLookup                  string               // token读取位置，逗号分隔按顺序读取，如 header:Authorization,cookie:token,query:token，默认 header:Authorization
+	JWTUserIDClaim                  string               // 用户ID声明，写入访问日志 uid 字段，默认 sub
+	JWTRolesClaim                   string               // 角色声明，写入 Identity.Roles，默认 roles
+	JWTScopesClaim                  string               // 授权范围声明，写入 Identity.Scopes，空格分隔的字符串或数组，默认 scope
+	JWTExcludeRoutes                []string             // 不需要鉴权的路由，语法同 AccessLogExcludeRoutes
+	EnableAPIKey                    bool                 // 是否开启API key鉴权，默认不开启
+	APIKeyLookup                    string               // API key读取位置，语法同 JWTTokenLookup，默认 header:X-Api-Key
+	APIKeys                         []APIKey             // 静态配置的API key，也用于签名鉴权，可以通过 WithAPIKeyProvider 自定义
+	APIKeyExcludeRoutes             []string             // 不需要API key鉴权的路由，语法同 AccessLogExcludeRoutes
+	EnableSignature                 bool                 // 是否开启HMAC签名鉴权，默认不开启
+	SignatureMaxSkew                time.Duration        // 签名时间戳允许的偏差，默认5m，nonce在两倍偏差时间内不能重复
+	SignatureMaxBodySize            int64                // 参与签名的body最大字节数，默认10MB
+	SignatureExcludeRoutes          []string             // 不需要签名鉴权的路由，语法同 AccessLogExcludeRoutes
+	AuthMode                        string               // JWT、API key、签名鉴权同时开启时的组合方式，all 需要全部通过，any 按 JWT、API key、签名的顺序任意一个通过即可，默认 all
+	EnableMTLS                      bool                 // 是否开启双向TLS鉴权，从客户端证书或者可信代理转发的header中提取身份，默认不开启
+	MTLSForwardedHeader             string               // 可信代理转发客户端证书的header，如 X-Forwarded-Client-Cert，为空时只使用TLS握手的证书
+	MTLSTrustedProxies              []string             // 可信代理的CIDR，只有来自可信代理的请求才会读取 MTLSForwardedHeader
+	MTLSAllowedIdentities           []string             // 全局证书白名单，path.Match 语法，匹配 SPIFFE ID、URI SAN、DNS SAN、CN，为空时不限制
+	MTLSRouteAllowedIdentities      map[string][]string  // 路由证书白名单，key 语法同 AccessLogExcludeRoutes，优先于全局白名单
+	MTLSExcludeRoutes               []string             // 不需要双向TLS鉴权的路由，语法同 AccessLogExcludeRoutes
+	EnableAuthz                     bool                 // 是否开启路由授权，默认不开启，路由通过 RequireRoles、RequireScopes、RequirePolicy 声明权限
+	AuthzDefaultPolicy              string               // 未声明权限的路由的默认策略，allow、authenticated、deny，默认allow
+	EnableIPFilter                  bool                 // 是否开启IP访问控制，默认不开启，规则支持热更新
+	IPAllowList                     []string             // 全局允许的CIDR或IP，为空时不限制
+	IPDenyList                      []string             // 全局拒绝的CIDR或IP，优先于允许列表
+	IPRouteRules                    map[string]IPRule    // 路由IP访问规则，key 语法同 AccessLogExcludeRoutes，路由的允许列表替代全局允许列表，拒绝列表同时生效
+	EnableOpenAPI                   bool                 // 是否开启 OpenAPI 3 文档接口，和业务路由一样经过IP访问控制、鉴权等中间件，默认不开启
+	OpenAPIPath                     string               // 文档路径，默认 /openapi.json
+	OpenAPITitle                    string               // 文档标题，默认应用名
+	OpenAPIDescription              string               // 文档描述
+	OpenAPIVersion                  string               // 文档版本，默认应用版本
+	EnableDocsUI                    bool                 // 是否开启内嵌的 Swagger UI 文档页面，默认不开启，页面读取 OpenAPIPath 的文档，开启后同时提供文档接口
+	DocsUIPath                      string               // 文档页面路径，默认 /docs/
+	DocsUIAppModes                  []string             // 允许开启文档页面的运行环境，对应 EGO_APP_MODE，如 dev、test，和 DocsUIAllowIPs 都为空时不开启文档页面
+	DocsUIAllowIPs                  []string             // 允许访问文档页面的CIDR或IP，为空时不限制IP
+	EnableRoutesEndpoint            bool                 // 是否开启路由信息接口，返回所有路由的方法、路径、注释、filter、元数据等，经过IP访问控制、鉴权等中间件，默认不开启
+	RoutesEndpointPath              string               // 路由信息接口路径，默认 /routes
+	EnableHealthCheck               bool                 // 是否开启 /healthz、/readyz、/livez 健康检查接口，默认开启，不经过 filter
+	HealthzPath                     string               // 全部检查，默认 /healthz
+	ReadyzPath                      string               // 是否可以接收流量，服务开始关闭后返回503，默认 /readyz
+	LivezPath                       string               // 进程是否存活，只执行 Liveness 检查，默认 /livez
+	HealthCheckTimeout              time.Duration        // 单个检查的默认超时时间，默认1s
+	HealthCheckCacheTTL             time.Duration        // 检查结果缓存时间，默认1s，避免探针频繁请求依赖
+	ShutdownDrainDelay              time.Duration        // 优雅关闭前的等待时间，期间 /readyz 返回503，等待负载均衡摘除流量后再关闭，默认不等待
+	EnableListenerInherit           bool                 // 是否继承 systemd socket activation（LISTEN_FDS）传递的监听socket，按 FileDescriptorName 等于组件名或者地址匹配，没有匹配时正常监听
+	EnableGracefulRestart           bool                 // 是否开启平滑重启，收到 SIGUSR2 时启动新进程并传递监听socket，新进程开始服务后向当前进程发送 SIGTERM 优雅退出
+	WebsocketHandshakeTimeout       time.Duration        // 握手时间
+	WebsocketReadBufferSize         int                  // WebsocketReadBufferSize
+	WebsocketWriteBufferSize        int                  // WebsocketWriteBufferSize
+	EnableWebsocketCompression      bool                 // 是否开通压缩
+	EnableWebsocketCheckOrigin      bool                 // 是否支持跨域
+	accessLogPolicy                 *accessLogPolicy     // 访问日志策略
+	rateLimitStore                  RateLimitStore
+	rateLimitKeyFunc                RateLimitKeyFunc
+	apiKeyProvider                  APIKeyProvider
+	policyEngine                    PolicyEngine
+	nonceStore                      NonceStore
+	container                       *restful.Container // restful 容器，默认为 restful.DefaultContainer
+	redactor                        *redactor          // 访问日志脱敏器
+	isolated                        bool               // 独立的组件，不监听配置变更、不更新包级别的组件
+	aiReqResCelPrg                  cel.Program
+	mu                              sync.RWMutex // mutex for EnableAccessInterceptor、EnableAccessInterceptorReq、EnableAccessInterceptorRes、SlowLogThreshold、AccessLogSampleRate、AccessInterceptorReqResFilter、aiReqResCelPrg
 }
 
 // DefaultConfig 反回默认配置
@@ -40,9 +156,22 @@ func DefaultConfig() *Config {
 		Port:                       9090,
 		Network:                    "tcp",
 		EnableAccessInterceptor:    true,
+		EnableRequestID:            true,
+		RequestIDHeader:            HeaderXRequestID,
 		EnableTraceInterceptor:     true,
 		EnableMetricInterceptor:    true,
 		SlowLogThreshold:           xtime.Duration("500ms"),
+		AccessLogSampleRate:        1,
+		OpenAPIPath:                defaultOpenAPIPath,
+		DocsUIPath:                 defaultDocsUIPath,
+		RoutesEndpointPath:         defaultRoutesPath,
+		EnableHealthCheck:          true,
+		HealthzPath:                defaultHealthzPath,
+		ReadyzPath:                 defaultReadyzPath,
+		LivezPath:                  defaultLivezPath,
+		HealthCheckTimeout:         xtime.Duration("1s"),
+		HealthCheckCacheTTL:        xtime.Duration("1s"),
+		SSEHeartbeatInterval:       xtime.Duration("15s"),
 		EnableWebsocketCheckOrigin: false,
 	}
 }
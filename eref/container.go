@@ -1,11 +1,16 @@
 package eref
 
 import (
+	"context"
+	"fmt"
 	"github.com/emicklei/go-restful/v3"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/gotomicro/ego/core/eapp"
 	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/gotomicro/ego/core/util/xnet"
-	"github.com/opentracing/opentracing-go"
+	rpcpb "google.golang.org/genproto/googleapis/rpc/context/attribute_context"
 )
 
 // Container 容器
@@ -35,6 +40,9 @@ func Load(key string) *Container {
 		host string
 		err  error
 	)
+	if err := c.setAiReqResCelPrg(); err != nil {
+		c.logger.Warn("init AccessInterceptorReqResFilter fail", elog.FieldErr(err), elog.String("AccessInterceptorReqResFilter", c.config.AccessInterceptorReqResFilter))
+	}
 	// 获取网卡ip
 	if c.config.EnableLocalMainIP {
 		host, _, err = xnet.GetLocalMainIP()
@@ -47,22 +55,261 @@ func Load(key string) *Container {
 	return c
 }
 
+var aiReqResCelEnv *cel.Env
+
+func init() {
+	var err error
+	aiReqResCelEnv, err = cel.NewEnv(
+		cel.Types(&rpcpb.AttributeContext_Request{}),
+		cel.Types(&rpcpb.AttributeContext_Response{}),
+		cel.Declarations(
+			decls.NewVar("request",
+				decls.NewObjectType("google.rpc.context.AttributeContext.Request"),
+			),
+			decls.NewVar("response",
+				decls.NewObjectType("google.rpc.context.AttributeContext.Response"),
+			),
+		),
+	)
+	if err != nil {
+		elog.Warn("invalid aiReqResCelEnv", elog.FieldErr(err))
+	}
+}
+
+// setAiReqResCelPrg 编译 AccessInterceptorReqResFilter 表达式，需要在持有写锁或初始化阶段调用
+func (c *Container) setAiReqResCelPrg() error {
+	if c.config.AccessInterceptorReqResFilter != "" {
+		c.logger.Info("load new AccessInterceptorReqResFilter", elog.String("filter", c.config.AccessInterceptorReqResFilter))
+		ast, iss := aiReqResCelEnv.Compile(c.config.AccessInterceptorReqResFilter)
+		if iss.Err() != nil {
+			return fmt.Errorf("invalid AccessInterceptorReqResFilter, %w", iss.Err())
+		}
+		prg, err := aiReqResCelEnv.Program(ast)
+		if err != nil {
+			return fmt.Errorf("build cel program fail , %w", err)
+		}
+		c.config.aiReqResCelPrg = prg
+		return nil
+	}
+	c.config.aiReqResCelPrg = nil
+	return nil
+}
+
 // Build 构建组件
-func (c *Container) Build() *Component {
+func (c *Container) Build(options ...Option) *Component {
+	for _, option := range options {
+		option(c)
+	}
+	if c.config.container == nil {
+		c.config.container = restful.DefaultContainer
+	}
+	container := c.config.container
 	server := newComponent(c.name, c.config, c.logger)
+	// 访问日志脱敏
+	redactor, err := newRedactor(c.config)
+	if err != nil {
+		c.logger.Panic("build redactor error", elog.FieldErr(err))
+	}
+	c.config.redactor = redactor
+	// 访问日志采样、路由级别
+	accessLogPolicy, err := newAccessLogPolicy(c.config)
+	if err != nil {
+		c.logger.Panic("build access log policy error", elog.FieldErr(err))
+	}
+	c.config.accessLogPolicy = accessLogPolicy
+	// 请求所属的组件，需要在其他中间件之前
+	container.Filter(server.componentFilter)
 	// 修正反代理IP
-	restful.Filter(filterProxyIp(c.logger, c.config))
+	container.Filter(filterProxyIp(c.logger, c.config))
+	// 请求ID
+	if c.config.EnableRequestID {
+		container.Filter(requestIDMiddleware(c.config))
+	}
+	// gzip 压缩，在错误恢复之前，访问日志记录的是压缩前的响应
+	if c.config.EnableGzip {
+		gzipFilter, err := gzipMiddleware(c.config.GzipLevel)
+		if err != nil {
+			c.logger.Panic("build gzip error", elog.FieldErr(err))
+		}
+		container.Filter(gzipFilter)
+	}
 	// 错误恢复
-	restful.Filter(recoverMiddleware(c.logger, c.config))
+	container.Filter(recoverMiddleware(c.logger, c.config))
+	// 监控、链路在访问控制、限流、鉴权之前，被拒绝的请求同样记录
+	if c.config.EnableMetricInterceptor {
+		initServerMetrics(c.config)
+		container.Filter(metricServerInterceptor())
+	}
+	if c.config.EnableTraceInterceptor && isTracerRegistered(c.config) {
+		excludes, err := newRouteMatcher("TraceExcludeRoutes", c.config.TraceExcludeRoutes)
+		if err != nil {
+			c.logger.Panic("build trace interceptor error", elog.FieldErr(err))
+		}
+		container.Filter(traceServerInterceptor(excludes, c.config.redactor))
+	}
+	// IP访问控制
+	var ipFilter *ipFilter
+	if c.config.EnableIPFilter {
+		var err error
+		ipFilter, err = newIPFilter(c.config)
+		if err != nil {
+			c.logger.Panic("build ip filter error", elog.FieldErr(err))
+		}
+		container.Filter(ipFilterMiddleware(ipFilter))
+	}
+	// 跨域
+	if c.config.EnableCORS {
+		cors, err := newCors(c.config, container)
+		if err != nil {
+			c.logger.Panic("build cors error", elog.FieldErr(err))
+		}
+		container.Filter(corsMiddleware(cors))
+	}
+	// 过载保护
+	if c.config.EnableLoadShedding {
+		shedder, err := newLoadShedder(c.config)
+		if err != nil {
+			c.logger.Panic("build load shedder error", elog.FieldErr(err))
+		}
+		container.Filter(loadSheddingMiddleware(shedder))
+	}
+	// 限流
+	if c.config.EnableRateLimit {
+		limiter, err := newRateLimiter(c.config)
+		if err != nil {
+			c.logger.Panic("build rate limiter error", elog.FieldErr(err))
+		}
+		container.Filter(rateLimitMiddleware(limiter))
+	}
+	// 双向TLS鉴权
+	if c.config.EnableMTLS {
+		auth, err := newMTLSAuth(c.config)
+		if err != nil {
+			c.logger.Panic("build mtls auth error", elog.FieldErr(err))
+		}
+		container.Filter(mtlsMiddleware(auth))
+	}
+	// JWT、API key、HMAC签名鉴权，按 AuthMode 组合
+	var auths []authenticator
+	if c.config.EnableJWT {
+		auth, err := newJWTAuth(c.config)
+		if err != nil {
+			c.logger.Panic("build jwt auth error", elog.FieldErr(err))
+		}
+		if auth.jwks != nil {
+			if err := auth.jwks.refresh(context.Background()); err != nil {
+				c.logger.Warn("preload jwks fail", elog.FieldErr(err))
+			}
+		}
+		auths = append(auths, auth)
+	}
+	if c.config.EnableAPIKey {
+		auth, err := newAPIKeyAuth(c.config)
+		if err != nil {
+			c.logger.Panic("build api key auth error", elog.FieldErr(err))
+		}
+		auths = append(auths, auth)
+	}
+	if c.config.EnableSignature {
+		auth, err := newSignatureAuth(c.config)
+		if err != nil {
+			c.logger.Panic("build signature auth error", elog.FieldErr(err))
+		}
+		auths = append(auths, auth)
+	}
+	switch c.config.AuthMode {
+	case "", AuthModeAll:
+		for _, auth := range auths {
+			container.Filter(authMiddleware(auth))
+		}
+	case AuthModeAny:
+		if len(auths) > 0 {
+			container.Filter(anyAuthMiddleware(auths))
+		}
+	default:
+		c.logger.Panic("build auth error", elog.FieldErr(fmt.Errorf("invalid AuthMode %q", c.config.AuthMode)))
+	}
+	// 路由授权，需要在鉴权之后
+	if c.config.EnableAuthz {
+		authorizer, err := newAuthorizer(c.config)
+		if err != nil {
+			c.logger.Panic("build authorizer error", elog.FieldErr(err))
+		}
+		container.Filter(authzMiddleware(authorizer))
+	}
 	if c.config.ContextTimeout > 0 {
-		restful.Filter(timeoutMiddleware(c.config.ContextTimeout))
+		container.Filter(timeoutMiddleware(c.config.ContextTimeout))
 	}
-	if c.config.EnableMetricInterceptor {
-		restful.Filter(metricServerInterceptor())
+	// 文档页面
+	var docs *docsUI
+	if c.config.EnableDocsUI {
+		docs, err = newDocsUI(c.config)
+		if err != nil {
+			c.logger.Panic("build docs ui error", elog.FieldErr(err))
+		}
+		if docs != nil {
+			server.handleBuiltin(docs.path, docs.ServeHTTP)
+		} else {
+			c.logger.Info("docs ui disabled, DocsUIAppModes and DocsUIAllowIPs are empty or current app mode is not allowed", elog.String("mode", eapp.AppMode()))
+		}
+	}
+	// OpenAPI 文档，每次请求时根据已注册的路由生成，文档页面读取该接口
+	if c.config.EnableOpenAPI || docs != nil {
+		server.addBuiltinRoute(c.config.OpenAPIPath, server.openAPIHandler)
+	}
+
+	// 路由信息
+	if c.config.EnableRoutesEndpoint {
+		server.addBuiltinRoute(c.config.RoutesEndpointPath, server.routesHandler)
+	}
+
+	// 健康检查
+	if c.config.EnableHealthCheck {
+		server.handleBuiltin(c.config.HealthzPath, server.health.handler(nil, false))
+		server.handleBuiltin(c.config.ReadyzPath, server.health.handler(nil, true))
+		server.handleBuiltin(c.config.LivezPath, server.health.handler(livenessCheck, false))
 	}
 
-	if c.config.EnableTraceInterceptor && opentracing.IsGlobalTracerRegistered() {
-		restful.Filter(traceServerInterceptor())
+	// 监听配置变更，热更新访问日志配置、IP访问规则
+	if c.name != "" && !c.config.isolated {
+		econf.OnChange(func(newConf *econf.Configuration) {
+			c.config.mu.Lock()
+			cf := newConf.Sub(c.name)
+			if cf.Get("EnableAccessInterceptor") != nil {
+				c.config.EnableAccessInterceptor = cf.GetBool("EnableAccessInterceptor")
+			}
+			c.config.EnableAccessInterceptorReq = cf.GetBool("EnableAccessInterceptorReq")
+			c.config.EnableAccessInterceptorRes = cf.GetBool("EnableAccessInterceptorRes")
+			if cf.Get("SlowLogThreshold") != nil {
+				c.config.SlowLogThreshold = cf.GetDuration("SlowLogThreshold")
+			}
+			if cf.Get("AccessLogSampleRate") != nil {
+				c.config.AccessLogSampleRate = cf.GetFloat64("AccessLogSampleRate")
+			}
+			if c.config.AccessInterceptorReqResFilter != cf.GetString("AccessInterceptorReqResFilter") {
+				c.config.AccessInterceptorReqResFilter = cf.GetString("AccessInterceptorReqResFilter")
+				if err := c.setAiReqResCelPrg(); err != nil {
+					c.logger.Warn("init AccessInterceptorReqResFilter fail", elog.FieldErr(err), elog.String("AccessInterceptorReqResFilter", c.config.AccessInterceptorReqResFilter))
+				}
+			}
+			c.config.mu.Unlock()
+
+			if ipFilter != nil {
+				var routes map[string]IPRule
+				if cf.Get("IPRouteRules") != nil {
+					if err := cf.UnmarshalKey("IPRouteRules", &routes); err != nil {
+						c.logger.Warn("reload ip filter fail", elog.FieldErr(err))
+						return
+					}
+				}
+				rules, err := newIPRules(cf.GetStringSlice("IPAllowList"), cf.GetStringSlice("IPDenyList"), routes)
+				if err != nil {
+					c.logger.Warn("reload ip filter fail", elog.FieldErr(err))
+					return
+				}
+				ipFilter.update(rules)
+			}
+		})
 	}
 
 	return server
@@ -0,0 +1,123 @@
+package eref
+
+import (
+	"encoding/json"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/econf"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changedSource 内存配置源，写入 changed 时触发 econf.OnChange
+type changedSource struct {
+	mu      sync.Mutex
+	content []byte
+	changed chan struct{}
+}
+
+func (s *changedSource) Parse(string, bool) econf.ConfigType {
+	return econf.ConfigTypeJSON
+}
+
+func (s *changedSource) ReadConfig() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content, nil
+}
+
+func (s *changedSource) IsConfigChanged() <-chan struct{} {
+	return s.changed
+}
+
+func (s *changedSource) Close() error {
+	return nil
+}
+
+// set 写入配置
+func (s *changedSource) set(t *testing.T, conf map[string]interface{}) {
+	t.Helper()
+	content, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	s.mu.Lock()
+	s.content = content
+	s.mu.Unlock()
+}
+
+// update 写入新配置并等待 econf.OnChange 回调执行完
+func (s *changedSource) update(t *testing.T, conf map[string]interface{}) {
+	t.Helper()
+	s.set(t, conf)
+	done := make(chan struct{})
+	var once sync.Once
+	econf.OnChange(func(*econf.Configuration) {
+		once.Do(func() { close(done) })
+	})
+	s.changed <- struct{}{}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change not applied")
+	}
+}
+
+func TestContainerReload(t *testing.T) {
+	const key = "eref_reload"
+	source := &changedSource{changed: make(chan struct{})}
+	source.set(t, map[string]interface{}{key: map[string]interface{}{
+		"EnableAccessInterceptor": true,
+		"EnableIPFilter":          true,
+		"IPAllowList":             []string{"192.0.2.0/24"},
+	}})
+	if err := econf.LoadFromDataSource(source, json.Unmarshal); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	c := Load(key).Build(WithContainer(restful.NewContainer()))
+	defer c.Stop()
+	ws := new(restful.WebService).Path("/api")
+	ws.Route(ws.GET("/ping").To(RouteContext(func(ctx Context) {})))
+	c.Add(ws)
+	status := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		c.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+	if got := status("192.0.2.1:1234"); got != http.StatusOK {
+		t.Fatalf("status before reload = %d, want %d", got, http.StatusOK)
+	}
+
+	source.update(t, map[string]interface{}{key: map[string]interface{}{
+		"EnableAccessInterceptor":       false,
+		"EnableAccessInterceptorReq":    true,
+		"EnableAccessInterceptorRes":    true,
+		"SlowLogThreshold":              "2s",
+		"AccessLogSampleRate":           0.5,
+		"AccessInterceptorReqResFilter": `request.path == "/api/ping"`,
+		"EnableIPFilter":                true,
+		"IPAllowList":                   []string{"10.0.0.0/8"},
+	}})
+	c.config.mu.RLock()
+	if c.config.EnableAccessInterceptor || !c.config.EnableAccessInterceptorReq || !c.config.EnableAccessInterceptorRes {
+		t.Errorf("access interceptor switches not reloaded")
+	}
+	if c.config.SlowLogThreshold != 2*time.Second || c.config.AccessLogSampleRate != 0.5 {
+		t.Errorf("SlowLogThreshold = %v, AccessLogSampleRate = %v", c.config.SlowLogThreshold, c.config.AccessLogSampleRate)
+	}
+	if c.config.aiReqResCelPrg == nil {
+		t.Error("AccessInterceptorReqResFilter not compiled")
+	}
+	c.config.mu.RUnlock()
+	// IP访问规则热更新
+	if got := status("192.0.2.1:1234"); got != http.StatusForbidden {
+		t.Errorf("status after reload = %d, want %d", got, http.StatusForbidden)
+	}
+	if got := status("10.0.0.1:1234"); got != http.StatusOK {
+		t.Errorf("status after reload = %d, want %d", got, http.StatusOK)
+	}
+}
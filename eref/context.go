@@ -1,19 +1,93 @@
 package eref
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"github.com/ego-plugin/binding"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/gotomicro/ego/core/elog"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strings"
 )
 
+// errBodyTooLarge 请求body超过限制
+var errBodyTooLarge = errors.New("request body too large")
+
+// logFieldsAttribute 中间件追加的日志字段在 restful.Request 中的属性名
+const logFieldsAttribute = "log_fields"
+
+// loggerAttribute 请求日志在 restful.Request 中的属性名，同一个请求的中间件和 handler 共用
+const loggerAttribute = "eref.logger"
+
 type Context struct {
 	*restful.Request
 	*restful.Response
-	Log *elog.Component
+	Log *elog.Component // 请求日志，带有方法、路由、客户端IP、链路ID、请求ID以及中间件追加的字段
+}
+
+// newContext 新建上下文，请求日志每个请求只构建一次，缓存在请求属性中
+func newContext(req *restful.Request, resp *restful.Response) Context {
+	c := Context{
+		Request:  req,
+		Response: resp,
+	}
+	if logger, ok := req.Attribute(loggerAttribute).(*elog.Component); ok {
+		c.Log = logger
+		return c
+	}
+	c.Log = c.newLogger()
+	req.SetAttribute(loggerAttribute, c.Log)
+	return c
+}
+
+// resetLogger 客户端IP、链路ID、请求ID由中间件写入，写入后调用，后面的中间件和handler新建上下文时重新构建请求日志
+func (c *Context) resetLogger() {
+	c.Request.SetAttribute(loggerAttribute, nil)
+}
+
+// newLogger 构建请求日志
+func (c Context) newLogger() *elog.Component {
+	logger := elog.EgoLogger
+	if comp := requestComponent(c.Request); comp != nil {
+		logger = comp.logger
+	}
+	fields := make([]elog.Field, 0, 8)
+	fields = append(fields,
+		elog.FieldMethod(c.Req().Method+"."+c.SelectedRoutePath()),
+		elog.FieldIP(c.ClientIP()),
+	)
+	if tid := extractTraceID(c.Context()); tid != "" {
+		fields = append(fields, elog.FieldTid(tid))
+	}
+	if id := c.RequestID(); id != "" {
+		fields = append(fields, fieldRequestID(id))
+	}
+	fields = append(fields, c.LogFields()...)
+	return logger.With(fields...)
+}
+
+// AddLogFields 追加请求日志字段，例如鉴权中间件追加用户ID
+// 追加的字段对后续的中间件、handler以及访问日志都生效
+func (c *Context) AddLogFields(fields ...elog.Field) {
+	extra := c.LogFields()
+	merged := make([]elog.Field, 0, len(extra)+len(fields))
+	merged = append(merged, extra...)
+	merged = append(merged, fields...)
+	c.Request.SetAttribute(logFieldsAttribute, merged)
+	c.Log = c.Log.With(fields...)
+	c.Request.SetAttribute(loggerAttribute, c.Log)
+}
+
+// LogFields 中间件追加的请求日志字段
+func (c Context) LogFields() []elog.Field {
+	if fields, ok := c.Request.Attribute(logFieldsAttribute).([]elog.Field); ok {
+		return fields
+	}
+	return nil
 }
 
 func (c Context) BindQuery(v any) error {
@@ -32,7 +106,7 @@ func (c Context) ClientIP() string {
 	if ip, ok := c.Request.Attribute("ip").(string); ok {
 		return ip
 	}
-	return getIP(c.Req())
+	return c.GetPeerIP()
 }
 
 func (c Context) GetPeerIP() string {
@@ -62,16 +136,48 @@ func (c Context) BodyToByte() []byte {
 	return []byte("")
 }
 
+// readBody 读取完整的请求body，读取后重置body供handler再次读取，并更新 BodyToByte 的结果
+// body超过limit时返回 errBodyTooLarge
+func (c Context) readBody(limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(c.Req().Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errBodyTooLarge
+	}
+	c.Req().Body = ioutil.NopCloser(bytes.NewReader(data))
+	c.SetAttribute("body", data)
+	return data, nil
+}
+
+// RequestID 请求ID，未开启 EnableRequestID 时为空
+func (c Context) RequestID() string {
+	if id, ok := c.Request.Attribute(requestIDAttribute).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// JWTClaims 校验通过的JWT声明，未开启 EnableJWT 或者路由不需要鉴权时返回false
+func (c Context) JWTClaims() (JWTClaims, bool) {
+	claims, ok := c.Request.Attribute(jwtClaimsAttribute).(JWTClaims)
+	return claims, ok
+}
+
+// APIKey API key或签名鉴权通过的调用方，不包含密钥
+func (c Context) APIKey() (APIKey, bool) {
+	key, ok := c.Request.Attribute(apiKeyAttribute).(APIKey)
+	return key, ok
+}
+
 type RouteContextFunc func(ctx Context)
 
 func RouteContext(f RouteContextFunc) restful.RouteFunction {
 	return func(req *restful.Request, resp *restful.Response) {
-		c := Context{
-			Request:  req,
-			Response: resp,
-			Log:      comp.logger,
-		}
-		f(c)
+		// handler 没有关闭的 SSE 流在返回时关闭，之后的中间件读取响应时心跳不会再写入
+		defer closeSSEStream(req)
+		f(newContext(req, resp))
 	}
 }
 
@@ -95,10 +201,7 @@ type FilterContextFunc func(ctx FilterContext)
 func Filter(f FilterContextFunc) restful.FilterFunction {
 	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 		c := FilterContext{
-			Context: Context{
-				Request:  req,
-				Response: resp,
-			},
+			Context:     newContext(req, resp),
 			FilterChain: chain,
 		}
 		f(c)
@@ -0,0 +1,94 @@
+package eref
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextLogFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := elog.DefaultContainer().Build(elog.WithZapCore(core))
+	config := DefaultConfig()
+	config.accessLogPolicy, _ = newAccessLogPolicy(config)
+	config.redactor, _ = newRedactor(config)
+	container := restful.NewContainer()
+	container.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		req.SetAttribute(componentAttribute, &Component{logger: logger})
+		chain.ProcessFilter(req, resp)
+	})
+	container.Filter(recoverMiddleware(logger, config))
+	// 请求ID之前的中间件已经构建了请求日志
+	container.Filter(Filter(func(ctx FilterContext) {
+		ctx.Log.Info("before")
+		ctx.ProcessFilter()
+	}))
+	container.Filter(requestIDMiddleware(config))
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/users/{id}").Filter(Filter(func(ctx FilterContext) {
+		ctx.AddLogFields(elog.String("uid", ctx.PathParameter("id")))
+		ctx.Log.Info("filter")
+		ctx.ProcessFilter()
+	})).To(RouteContext(func(ctx Context) {
+		ctx.Log.Info("handled")
+	})))
+	container.Add(ws)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/u1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	id := w.Header().Get(HeaderXRequestID)
+
+	for message, want := range map[string]map[string]interface{}{
+		"before":  {"method": "GET./users/{id}", "ip": "192.0.2.1"},
+		"filter":  {"method": "GET./users/{id}", "ip": "192.0.2.1", "rid": id, "uid": "u1"},
+		"handled": {"method": "GET./users/{id}", "ip": "192.0.2.1", "rid": id, "uid": "u1"},
+		"access":  {"method": "GET./users/{id}", "ip": "192.0.2.1", "rid": id, "uid": "u1"},
+	} {
+		entries := logs.FilterMessage(message).All()
+		if len(entries) != 1 {
+			t.Fatalf("%s logged %d times", message, len(entries))
+		}
+		fields := entries[0].ContextMap()
+		for k, v := range want {
+			if fields[k] != v {
+				t.Errorf("%s field %s = %v, want %v", message, k, fields[k], v)
+			}
+		}
+		// 字段不重复
+		seen := make(map[string]bool)
+		for _, field := range entries[0].Context {
+			if seen[field.Key] {
+				t.Errorf("%s field %s duplicated", message, field.Key)
+			}
+			seen[field.Key] = true
+		}
+	}
+	if _, ok := logs.FilterMessage("before").All()[0].ContextMap()["uid"]; ok {
+		t.Error("fields added by later filters leaked into earlier logs")
+	}
+}
+
+func TestFilterContextLogWithoutComponent(t *testing.T) {
+	// 没有经过组件容器时使用默认日志，不为空
+	container := restful.NewContainer()
+	container.Filter(Filter(func(ctx FilterContext) {
+		if ctx.Log == nil {
+			t.Error("FilterContext.Log is nil")
+		}
+		ctx.ProcessFilter()
+	}))
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/ping").To(RouteContext(func(ctx Context) {
+		if ctx.Log == nil {
+			t.Error("Context.Log is nil")
+		}
+	})))
+	container.Add(ws)
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+}
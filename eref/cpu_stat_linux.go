@@ -0,0 +1,126 @@
+//go:build linux
+
+package eref
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cgroupCPUBase cgroup CPU采样的起点，换算成和 /proc/stat 相同的 idle、total 累计值
+var cgroupCPUBase struct {
+	once  sync.Once
+	start time.Time
+	usage uint64
+	err   error
+}
+
+// readCPUStat 容器中按 cgroup 的CPU使用时间和配额计算，读取不到 cgroup 时使用整机的 /proc/stat
+func readCPUStat() (idle, total uint64, err error) {
+	if idle, total, err = readCgroupCPUStat(); err == nil {
+		return idle, total, nil
+	}
+	return readProcCPUStat()
+}
+
+// readCgroupCPUStat total 为经过的时间乘以可用CPU数量，idle 为 total 减去 cgroup 使用的CPU时间，单位微秒
+func readCgroupCPUStat() (idle, total uint64, err error) {
+	base := &cgroupCPUBase
+	base.once.Do(func() {
+		base.start = time.Now()
+		base.usage, base.err = cgroupCPUUsage()
+	})
+	if base.err != nil {
+		return 0, 0, base.err
+	}
+	usage, err := cgroupCPUUsage()
+	if err != nil {
+		return 0, 0, err
+	}
+	total = base.usage + uint64(float64(time.Since(base.start).Microseconds())*cgroupCPULimit())
+	if usage > total {
+		usage = total
+	}
+	return total - usage, total, nil
+}
+
+// cgroupCPUUsage cgroup 累计使用的CPU时间，单位微秒，支持 cgroup v2 和 v1
+func cgroupCPUUsage() (uint64, error) {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.stat"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				return strconv.ParseUint(fields[1], 10, 64)
+			}
+		}
+		return 0, errors.New("invalid cgroup cpu.stat")
+	}
+	data, err := os.ReadFile("/sys/fs/cgroup/cpuacct/cpuacct.usage")
+	if err != nil {
+		return 0, err
+	}
+	ns, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ns / 1000, nil
+}
+
+// cgroupCPULimit cgroup 的CPU配额，没有限制时为可用的CPU数量
+func cgroupCPULimit() float64 {
+	limit := float64(runtime.NumCPU())
+	var quota, period string
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		// 格式为 "$MAX $PERIOD"，不限制时 $MAX 为 max
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			quota, period = fields[0], fields[1]
+		}
+	} else {
+		q, qErr := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+		p, pErr := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+		if qErr == nil && pErr == nil {
+			quota, period = strings.TrimSpace(string(q)), strings.TrimSpace(string(p))
+		}
+	}
+	q, qErr := strconv.ParseFloat(quota, 64)
+	p, pErr := strconv.ParseFloat(period, 64)
+	if qErr == nil && pErr == nil && q > 0 && p > 0 && q/p < limit {
+		return q / p
+	}
+	return limit
+}
+
+// readProcCPUStat 读取 /proc/stat 中整机的CPU累计时间
+func readProcCPUStat() (idle, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, errors.New("empty /proc/stat")
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, errors.New("invalid /proc/stat")
+	}
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += v
+		// idle、iowait
+		if i == 3 || i == 4 {
+			idle += v
+		}
+	}
+	return idle, total, nil
+}
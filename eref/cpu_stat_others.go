@@ -0,0 +1,10 @@
+//go:build !linux
+
+package eref
+
+import "errors"
+
+// readCPUStat 非linux平台暂不支持CPU采样
+func readCPUStat() (idle, total uint64, err error) {
+	return 0, 0, errors.New("cpu stat is not supported on this platform")
+}
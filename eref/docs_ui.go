@@ -0,0 +1,105 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/gotomicro/ego/core/eapp"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// defaultDocsUIPath 默认的文档页面路径
+const defaultDocsUIPath = "/docs/"
+
+// docsUIInitializer 替换 Swagger UI 默认的初始化脚本，指向当前服务的文档
+const docsUIInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// docsUI 内嵌的 Swagger UI 文档页面，静态资源打包在二进制中，不需要访问外网
+// 页面读取 OpenAPIPath 的文档，文档接口经过IP访问控制、鉴权等中间件
+type docsUI struct {
+	path     string
+	specURL  string
+	allowIPs ipNets
+	proxies  *proxyTrust
+	files    http.Handler
+}
+
+// newDocsUI 根据配置构建文档页面，默认关闭
+// 没有配置 DocsUIAppModes、DocsUIAllowIPs，或者当前运行环境不在 DocsUIAppModes 中时返回nil
+func newDocsUI(config *Config) (*docsUI, error) {
+	if len(config.DocsUIAppModes) == 0 && len(config.DocsUIAllowIPs) == 0 {
+		return nil, nil
+	}
+	if len(config.DocsUIAppModes) > 0 && !containsString(config.DocsUIAppModes, eapp.AppMode()) {
+		return nil, nil
+	}
+	allowIPs, err := parseIPNets("DocsUIAllowIPs", config.DocsUIAllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := newProxyTrust(config)
+	if err != nil {
+		return nil, err
+	}
+	path := config.DocsUIPath
+	if path == "" {
+		path = defaultDocsUIPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid DocsUIPath %q, must start with /", path)
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	specURL := config.OpenAPIPath
+	if specURL == "" {
+		specURL = defaultOpenAPIPath
+	}
+	return &docsUI{
+		path:     path,
+		specURL:  specURL,
+		allowIPs: allowIPs,
+		proxies:  proxies,
+		files:    http.StripPrefix(path, http.FileServer(http.FS(swaggerFiles.FS))),
+	}, nil
+}
+
+// ServeHTTP implements http.Handler
+func (d *docsUI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(d.allowIPs) > 0 && !d.allowIPs.Contains(d.proxies.clientIP(r)) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, d.path) {
+	case "swagger-initializer.js":
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		_, _ = fmt.Fprintf(w, docsUIInitializer, d.specURL)
+	case "", "index.html":
+		// FileServer 会把 index.html 重定向到目录，这里直接返回页面
+		data, err := fs.ReadFile(swaggerFiles.FS, "index.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(data)
+	default:
+		d.files.ServeHTTP(w, r)
+	}
+}
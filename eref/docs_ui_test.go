@@ -0,0 +1,44 @@
+package eref_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDocsUIClosedByDefault(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableDocsUI": true})
+
+	// 没有配置运行环境和IP白名单时不开启
+	s.GET("/docs/").Do().ExpectStatus(http.StatusNotFound)
+	s.GET("/openapi.json").Do().ExpectStatus(http.StatusNotFound)
+}
+
+func TestDocsUIAppModes(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableDocsUI":   true,
+		"DocsUIAppModes": []string{"eref-test-mode"},
+	})
+
+	s.GET("/docs/").Do().ExpectStatus(http.StatusNotFound)
+}
+
+func TestDocsUIAllowIPs(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableDocsUI":   true,
+		"DocsUIAllowIPs": []string{"192.0.2.0/24"},
+		"EnableJWT":      true,
+		"JWTSecret":      testJWTSecret,
+	})
+
+	const allowed = "192.0.2.1:1234"
+	s.GET("/docs/").RemoteAddr(allowed).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", "text/html; charset=utf-8")
+	// 页面读取经过中间件的文档接口，不再单独提供文档
+	s.GET("/docs/swagger-initializer.js").RemoteAddr(allowed).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains(`url: "/openapi.json"`)
+	s.GET("/docs/openapi.json").RemoteAddr(allowed).Do().ExpectStatus(http.StatusNotFound)
+	s.GET("/openapi.json").RemoteAddr(allowed).Do().ExpectStatus(http.StatusUnauthorized)
+	s.GET("/docs/").RemoteAddr("198.51.100.1:1234").Do().ExpectStatus(http.StatusForbidden)
+}
@@ -0,0 +1,32 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/econf"
+	"strings"
+	"testing"
+)
+
+// loadServer 以测试名作为配置key加载配置，构建开启对应中间件的测试服务
+func loadServer(t *testing.T, conf map[string]interface{}, options ...eref.Option) *ereftest.Server {
+	t.Helper()
+	key := "eref_test_" + strings.ToLower(strings.NewReplacer("/", "_", " ", "_").Replace(t.Name()))
+	if err := econf.Apply(map[string]interface{}{key: conf}); err != nil {
+		t.Fatalf("apply config: %v", err)
+	}
+	return ereftest.Load(t, key, options...)
+}
+
+// identityRoute 返回调用方身份的路由，未经过鉴权时返回空身份
+func identityRoute(ws *restful.WebService, path string, builders ...func(*restful.RouteBuilder)) {
+	rb := ws.GET(path).To(eref.RouteContext(func(ctx eref.Context) {
+		identity, _ := ctx.Identity()
+		_ = ctx.WriteEntity(identity)
+	}))
+	for _, b := range builders {
+		rb.Do(b)
+	}
+	ws.Route(rb)
+}
@@ -0,0 +1,102 @@
+package ereftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ego-plugin/server/eref"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// Request 请求构建器
+type Request struct {
+	s          *Server
+	method     string
+	path       string
+	header     http.Header
+	query      url.Values
+	body       []byte
+	remoteAddr string
+	err        error
+}
+
+// Header 设置请求头
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query 追加查询参数
+func (r *Request) Query(key, value string) *Request {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// RemoteAddr 设置客户端地址，默认 192.0.2.1:1234
+func (r *Request) RemoteAddr(addr string) *Request {
+	r.remoteAddr = addr
+	return r
+}
+
+// Body 设置请求体
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.body = body
+	return r.Header(restful.HEADER_ContentType, contentType)
+}
+
+// JSON 使用JSON编码请求体，并且期望JSON响应
+func (r *Request) JSON(v interface{}) *Request {
+	body, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body(restful.MIME_JSON, body).Header(restful.HEADER_Accept, restful.MIME_JSON)
+}
+
+// MsgPack 使用msgpack编码请求体，并且期望msgpack响应
+func (r *Request) MsgPack(v interface{}) *Request {
+	body, err := msgpack.Marshal(v)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body(eref.MIME_MSGPACK, body).Header(restful.HEADER_Accept, eref.MIME_MSGPACK)
+}
+
+// Do 在进程内处理请求
+func (r *Request) Do() *Response {
+	t := r.s.t
+	t.Helper()
+	if r.err != nil {
+		t.Fatalf("ereftest: build %s %s request: %v", r.method, r.path, r.err)
+	}
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req := httptest.NewRequest(r.method, target, bytes.NewReader(r.body))
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if r.remoteAddr != "" {
+		req.RemoteAddr = r.remoteAddr
+	}
+	rec := httptest.NewRecorder()
+	r.s.Component.Handler().ServeHTTP(rec, req)
+	return &Response{
+		t:        t,
+		method:   r.method,
+		path:     r.path,
+		Recorder: rec,
+	}
+}
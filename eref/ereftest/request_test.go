@@ -0,0 +1,123 @@
+package ereftest_test
+
+import (
+	"fmt"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordingT 记录断言失败，不终止测试
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// take 取出记录的失败
+func (r *recordingT) take() []string {
+	failures := r.failures
+	r.failures = nil
+	return failures
+}
+
+type item struct {
+	Name  string `json:"name" msgpack:"name"`
+	Count int    `json:"count" msgpack:"count"`
+}
+
+// itemRoutes 回显请求的路由
+func itemRoutes() *restful.WebService {
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.POST("/items").
+		Consumes(restful.MIME_JSON, eref.MIME_MSGPACK).
+		Produces(restful.MIME_JSON, eref.MIME_MSGPACK).
+		To(eref.RouteContext(func(ctx eref.Context) {
+			var in item
+			if err := ctx.ReadEntity(&in); err != nil {
+				_ = ctx.WriteErrorString(http.StatusBadRequest, err.Error())
+				return
+			}
+			in.Count++
+			ctx.Response.AddHeader("X-Item", in.Name)
+			_ = ctx.WriteEntity(in)
+		})))
+	ws.Route(ws.GET("/echo").To(eref.RouteContext(func(ctx eref.Context) {
+		_, _ = fmt.Fprintf(ctx, "%s %s %s", ctx.QueryParameter("q"), ctx.HeaderParameter("X-Custom"), ctx.ClientIP())
+	})))
+	return ws
+}
+
+func TestRequestBuilders(t *testing.T) {
+	s := ereftest.New(t).Add(itemRoutes())
+
+	s.GET("/api/echo?q=a").Query("q", "b").Header("X-Custom", "c").RemoteAddr("192.0.2.1:1234").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("a c 192.0.2.1")
+	s.GET("/api/echo").Query("q", "a b").Do().ExpectBodyContains("a b ")
+
+	s.POST("/api/items").JSON(item{Name: "apple", Count: 1}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Item", "apple").
+		ExpectHeader("Content-Type", restful.MIME_JSON).
+		ExpectJSON(item{Name: "apple", Count: 2}).
+		ExpectJSON(`{"count": 2, "name": "apple"}`).
+		ExpectJSON(map[string]interface{}{"name": "apple", "count": 2})
+
+	var got item
+	s.POST("/api/items").MsgPack(item{Name: "pear", Count: 4}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", eref.MIME_MSGPACK).
+		DecodeMsgPack(&got)
+	if got != (item{Name: "pear", Count: 5}) {
+		t.Errorf("msgpack response = %+v", got)
+	}
+
+	s.PUT("/api/items").Do().ExpectStatus(http.StatusMethodNotAllowed)
+	s.PATCH("/api/items").Do().ExpectStatus(http.StatusMethodNotAllowed)
+	s.DELETE("/api/items").Do().ExpectStatus(http.StatusMethodNotAllowed)
+}
+
+func TestResponseAssertionFailures(t *testing.T) {
+	rt := &recordingT{TB: t}
+	s := ereftest.New(rt).Add(itemRoutes())
+	res := s.POST("/api/items").JSON(item{Name: "apple"}).Do()
+
+	for name, assert := range map[string]func(){
+		"status":   func() { res.ExpectStatus(http.StatusCreated) },
+		"header":   func() { res.ExpectHeader("X-Item", "pear") },
+		"body":     func() { res.ExpectBody("{}") },
+		"contains": func() { res.ExpectBodyContains("pear") },
+		"json":     func() { res.ExpectJSON(item{Name: "apple"}) },
+		"decode":   func() { res.DecodeMsgPack(&item{}) },
+	} {
+		assert()
+		if failures := rt.take(); len(failures) != 1 || !strings.Contains(failures[0], "POST /api/items") {
+			t.Errorf("%s assertion failures = %q, want one failure with the request", name, failures)
+		}
+	}
+
+	// 断言成功时不记录失败
+	res.ExpectStatus(http.StatusOK).ExpectHeader("X-Item", "apple").ExpectJSON(item{Name: "apple", Count: 1})
+	if failures := rt.take(); len(failures) != 0 {
+		t.Errorf("passing assertions recorded failures %q", failures)
+	}
+
+	// 请求体编码失败时终止测试
+	s.POST("/api/items").JSON(func() {}).Do()
+	if failures := rt.take(); len(failures) == 0 || !strings.Contains(failures[0], "build POST /api/items request") {
+		t.Errorf("json encode failures = %q", failures)
+	}
+}
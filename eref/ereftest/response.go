@@ -0,0 +1,120 @@
+package ereftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Response 响应断言，断言失败时调用 t.Errorf，后续断言继续执行
+type Response struct {
+	t        testing.TB
+	method   string
+	path     string
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status 响应状态码
+func (r *Response) Status() int {
+	return r.Recorder.Code
+}
+
+// Body 响应体
+func (r *Response) Body() []byte {
+	return r.Recorder.Body.Bytes()
+}
+
+// Header 响应头
+func (r *Response) Header(key string) string {
+	return r.Recorder.Header().Get(key)
+}
+
+// ExpectStatus 断言状态码
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("ereftest: %s %s status = %d, want %d, body: %s", r.method, r.path, r.Recorder.Code, code, r.Recorder.Body.String())
+	}
+	return r
+}
+
+// ExpectHeader 断言响应头
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header(key); got != value {
+		r.t.Errorf("ereftest: %s %s header %s = %q, want %q", r.method, r.path, key, got, value)
+	}
+	return r
+}
+
+// ExpectBody 断言响应体，忽略首尾空白
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	if got := strings.TrimSpace(r.Recorder.Body.String()); got != strings.TrimSpace(body) {
+		r.t.Errorf("ereftest: %s %s body = %q, want %q", r.method, r.path, got, body)
+	}
+	return r
+}
+
+// ExpectBodyContains 断言响应体包含 sub
+func (r *Response) ExpectBodyContains(sub string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Recorder.Body.String(), sub) {
+		r.t.Errorf("ereftest: %s %s body = %q, want contains %q", r.method, r.path, r.Recorder.Body.String(), sub)
+	}
+	return r
+}
+
+// ExpectJSON 断言JSON响应体，expected 可以是结构体、map 或者JSON字符串，比较时忽略字段顺序和格式
+func (r *Response) ExpectJSON(expected interface{}) *Response {
+	r.t.Helper()
+	var want []byte
+	switch v := expected.(type) {
+	case string:
+		want = []byte(v)
+	case []byte:
+		want = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			r.t.Errorf("ereftest: marshal expected json: %v", err)
+			return r
+		}
+		want = data
+	}
+	var got, exp interface{}
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), &got); err != nil {
+		r.t.Errorf("ereftest: %s %s body is not json: %v, body: %s", r.method, r.path, err, r.Recorder.Body.String())
+		return r
+	}
+	if err := json.Unmarshal(want, &exp); err != nil {
+		r.t.Errorf("ereftest: expected is not json: %v", err)
+		return r
+	}
+	if !reflect.DeepEqual(got, exp) {
+		r.t.Errorf("ereftest: %s %s json = %s, want %s", r.method, r.path, bytes.TrimSpace(r.Recorder.Body.Bytes()), want)
+	}
+	return r
+}
+
+// DecodeJSON 解析JSON响应体，失败时终止测试
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("ereftest: %s %s decode json: %v, body: %s", r.method, r.path, err, r.Recorder.Body.String())
+	}
+	return r
+}
+
+// DecodeMsgPack 解析msgpack响应体，失败时终止测试
+func (r *Response) DecodeMsgPack(v interface{}) *Response {
+	r.t.Helper()
+	if err := msgpack.Unmarshal(r.Recorder.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("ereftest: %s %s decode msgpack: %v", r.method, r.path, err)
+	}
+	return r
+}
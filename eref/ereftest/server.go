@@ -0,0 +1,127 @@
+// Package ereftest eref 的测试工具
+// 每个测试使用独立的 restful 容器，请求直接在进程内处理，不需要监听端口
+package ereftest
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server 测试服务
+type Server struct {
+	t         testing.TB
+	Component *eref.Component // 使用独立容器构建的组件，中间件和 Build 一致
+
+	mu     sync.Mutex
+	server *httptest.Server // websocket 等需要真实连接时按需启动
+}
+
+// New 构建测试服务，测试结束时自动关闭
+// options 和 eref.Container.Build 相同，例如 eref.WithContextTimeout
+func New(t testing.TB, options ...eref.Option) *Server {
+	t.Helper()
+	return newServer(t, eref.DefaultContainer(), options)
+}
+
+// Load 使用配置key构建测试服务，配置需要先通过 econf 加载，例如 econf.LoadFromReader、econf.Apply
+// 用于开启鉴权、IP访问控制等需要配置的中间件，测试服务不监听配置变更
+func Load(t testing.TB, key string, options ...eref.Option) *Server {
+	t.Helper()
+	return newServer(t, eref.Load(key), options)
+}
+
+// newServer 使用独立容器构建独立的组件，不注册配置变更回调、不影响 eref.BuildWebsocket
+func newServer(t testing.TB, container *eref.Container, options []eref.Option) *Server {
+	opts := append([]eref.Option{eref.WithContainer(restful.NewContainer()), eref.WithIsolated()}, options...)
+	s := &Server{
+		t:         t,
+		Component: container.Build(opts...),
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Add 注册 WebService
+func (s *Server) Add(services ...*restful.WebService) *Server {
+	s.Component.Add(services...)
+	return s
+}
+
+// AddWebService 注册 eref.WebService
+func (s *Server) AddWebService(ws *eref.WebService) *Server {
+	ws.BuildTo(s.Component.Container())
+	return s
+}
+
+// Handler 组件的 http.Handler
+func (s *Server) Handler() http.Handler {
+	return s.Component.Handler()
+}
+
+// URL 启动 httptest.Server 并返回地址，例如 http://127.0.0.1:1234
+func (s *Server) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		s.server = httptest.NewServer(s.Component.Handler())
+	}
+	return s.server.URL
+}
+
+// wsURL websocket 地址
+func (s *Server) wsURL(path string) string {
+	return "ws" + strings.TrimPrefix(s.URL(), "http") + path
+}
+
+// Close 关闭 httptest.Server 和组件
+func (s *Server) Close() {
+	s.mu.Lock()
+	server := s.server
+	s.server = nil
+	s.mu.Unlock()
+	if server != nil {
+		server.CloseClientConnections()
+		server.Close()
+	}
+	_ = s.Component.Stop()
+}
+
+// NewRequest 新建请求
+func (s *Server) NewRequest(method, path string) *Request {
+	return &Request{
+		s:      s,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+	}
+}
+
+// GET 新建 GET 请求
+func (s *Server) GET(path string) *Request {
+	return s.NewRequest(http.MethodGet, path)
+}
+
+// POST 新建 POST 请求
+func (s *Server) POST(path string) *Request {
+	return s.NewRequest(http.MethodPost, path)
+}
+
+// PUT 新建 PUT 请求
+func (s *Server) PUT(path string) *Request {
+	return s.NewRequest(http.MethodPut, path)
+}
+
+// PATCH 新建 PATCH 请求
+func (s *Server) PATCH(path string) *Request {
+	return s.NewRequest(http.MethodPatch, path)
+}
+
+// DELETE 新建 DELETE 请求
+func (s *Server) DELETE(path string) *Request {
+	return s.NewRequest(http.MethodDelete, path)
+}
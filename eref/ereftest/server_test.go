@@ -0,0 +1,91 @@
+package ereftest_test
+
+import (
+	"encoding/json"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"github.com/gotomicro/ego/core/econf"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changedSource 内存配置源，写入 changed 时触发 econf.OnChange
+type changedSource struct {
+	mu      sync.Mutex
+	content []byte
+	changed chan struct{}
+}
+
+func (s *changedSource) Parse(string, bool) econf.ConfigType {
+	return econf.ConfigTypeJSON
+}
+
+func (s *changedSource) ReadConfig() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content, nil
+}
+
+func (s *changedSource) IsConfigChanged() <-chan struct{} {
+	return s.changed
+}
+
+func (s *changedSource) Close() error {
+	return nil
+}
+
+// update 写入新配置并等待 econf.OnChange 回调执行完
+func (s *changedSource) update(t *testing.T, conf map[string]interface{}) {
+	t.Helper()
+	content, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	s.mu.Lock()
+	s.content = content
+	s.mu.Unlock()
+	done := make(chan struct{})
+	var once sync.Once
+	econf.OnChange(func(*econf.Configuration) {
+		once.Do(func() { close(done) })
+	})
+	s.changed <- struct{}{}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change not applied")
+	}
+}
+
+func TestLoadIsolated(t *testing.T) {
+	const key = "ereftest_isolated"
+	source := &changedSource{changed: make(chan struct{})}
+	source.content, _ = json.Marshal(map[string]interface{}{key: map[string]interface{}{
+		"EnableIPFilter":             true,
+		"IPAllowList":                []string{"192.0.2.0/24"},
+		"EnableWebsocketCheckOrigin": true,
+	}})
+	if err := econf.LoadFromDataSource(source, json.Unmarshal); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	checkOrigin := eref.BuildWebsocket().CheckOrigin != nil
+
+	s := ereftest.Load(t, key)
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/ping").To(eref.RouteContext(func(ctx eref.Context) {})))
+	s.Add(ws)
+	s.GET("/api/ping").RemoteAddr("192.0.2.1:1234").Do().ExpectStatus(http.StatusOK)
+
+	// 测试组件不作为 BuildWebsocket 使用的组件
+	if got := eref.BuildWebsocket().CheckOrigin != nil; got != checkOrigin {
+		t.Errorf("BuildWebsocket uses the test component, CheckOrigin set = %v, want %v", got, checkOrigin)
+	}
+	// 测试组件不监听配置变更
+	source.update(t, map[string]interface{}{key: map[string]interface{}{
+		"EnableIPFilter": true,
+		"IPAllowList":    []string{"10.0.0.0/8"},
+	}})
+	s.GET("/api/ping").RemoteAddr("192.0.2.1:1234").Do().ExpectStatus(http.StatusOK)
+}
@@ -0,0 +1,85 @@
+package ereftest
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// WebSocketTimeout websocket 读写超时
+var WebSocketTimeout = 5 * time.Second
+
+// WebSocketConn websocket 测试客户端，读写失败时终止测试
+type WebSocketConn struct {
+	*websocket.Conn
+	t testing.TB
+}
+
+// WebSocket 通过 httptest.Server 连接 websocket 路由，测试结束时自动关闭
+func (s *Server) WebSocket(path string, header http.Header) *WebSocketConn {
+	s.t.Helper()
+	dialer := websocket.Dialer{HandshakeTimeout: WebSocketTimeout}
+	conn, resp, err := dialer.Dial(s.wsURL(path), header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		s.t.Fatalf("ereftest: dial websocket %s: %v, status: %d", path, err, status)
+	}
+	s.t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return &WebSocketConn{Conn: conn, t: s.t}
+}
+
+// SendText 发送文本消息
+func (c *WebSocketConn) SendText(msg string) *WebSocketConn {
+	c.t.Helper()
+	_ = c.SetWriteDeadline(time.Now().Add(WebSocketTimeout))
+	if err := c.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		c.t.Fatalf("ereftest: websocket write: %v", err)
+	}
+	return c
+}
+
+// SendJSON 发送JSON消息
+func (c *WebSocketConn) SendJSON(v interface{}) *WebSocketConn {
+	c.t.Helper()
+	_ = c.SetWriteDeadline(time.Now().Add(WebSocketTimeout))
+	if err := c.WriteJSON(v); err != nil {
+		c.t.Fatalf("ereftest: websocket write json: %v", err)
+	}
+	return c
+}
+
+// ReadText 读取一条消息
+func (c *WebSocketConn) ReadText() string {
+	c.t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(WebSocketTimeout))
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		c.t.Fatalf("ereftest: websocket read: %v", err)
+	}
+	return string(data)
+}
+
+// DecodeJSON 读取一条JSON消息
+func (c *WebSocketConn) DecodeJSON(v interface{}) *WebSocketConn {
+	c.t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(WebSocketTimeout))
+	if err := c.ReadJSON(v); err != nil {
+		c.t.Fatalf("ereftest: websocket read json: %v", err)
+	}
+	return c
+}
+
+// ExpectText 断言下一条消息
+func (c *WebSocketConn) ExpectText(msg string) *WebSocketConn {
+	c.t.Helper()
+	if got := c.ReadText(); got != msg {
+		c.t.Errorf("ereftest: websocket message = %q, want %q", got, msg)
+	}
+	return c
+}
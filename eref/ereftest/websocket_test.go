@@ -0,0 +1,73 @@
+package ereftest_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWebSocket(t *testing.T) {
+	s := ereftest.New(t)
+	ws := eref.NewRoute("/ws")
+	ws.Route(ws.GET("/echo").To(s.Component.UpgradeRoute(s.Component.BuildWebsocket(), func(conn *eref.WebSocketConn, err error) {
+		if err != nil {
+			return
+		}
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return
+			}
+		}
+	})))
+	s.Add(ws)
+
+	conn := s.WebSocket("/ws/echo", nil)
+	conn.SendText("hello").ExpectText("hello")
+	var got item
+	conn.SendJSON(item{Name: "apple", Count: 1}).DecodeJSON(&got)
+	if got != (item{Name: "apple", Count: 1}) {
+		t.Errorf("json message = %+v", got)
+	}
+	// 同一个测试服务复用 httptest.Server，可以建立多个连接
+	if !strings.HasPrefix(s.URL(), "http://127.0.0.1:") {
+		t.Errorf("URL = %s", s.URL())
+	}
+	s.WebSocket("/ws/echo", http.Header{"X-Custom": {"1"}}).SendText("second").ExpectText("second")
+}
+
+func TestURLAndClose(t *testing.T) {
+	s := ereftest.New(t)
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/ping").To(eref.RouteContext(func(ctx eref.Context) {
+		_, _ = ctx.Write([]byte("pong"))
+	})))
+	s.Add(ws)
+
+	url := s.URL()
+	if s.URL() != url {
+		t.Errorf("URL changed between calls")
+	}
+	resp, err := http.Get(url + "/api/ping")
+	if err != nil {
+		t.Fatalf("request httptest server: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+
+	s.Close()
+	if resp, err := http.Get(url + "/api/ping"); err == nil {
+		_ = resp.Body.Close()
+		t.Error("httptest server still serving after Close")
+	}
+	// 关闭后仍然可以在进程内处理请求，重复关闭不报错
+	s.GET("/api/ping").Do().ExpectBody("pong")
+	s.Close()
+}
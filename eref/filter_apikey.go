@@ -0,0 +1,117 @@
+package eref
+
+import (
+	"context"
+	"errors"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+)
+
+// HeaderXAPIKey 默认的API key header
+const HeaderXAPIKey = "X-Api-Key"
+
+// apiKeyAttribute 调用方API key在 restful.Request 中的属性名
+const apiKeyAttribute = "api_key"
+
+// APIKey 服务间调用的凭证
+type APIKey struct {
+	ID     string   // 调用方标识，写入访问日志 apikey 字段
+	Key    string   // API key，签名鉴权时作为 X-Access-Key
+	Secret string   // 签名鉴权的HMAC密钥
+	Roles  []string // 调用方拥有的角色
+}
+
+// APIKeyProvider API key来源，默认使用配置中的 APIKeys，可以通过 WithAPIKeyProvider 接入数据库等
+type APIKeyProvider interface {
+	// APIKey 根据key查找凭证，不存在时返回nil
+	APIKey(ctx context.Context, key string) (*APIKey, error)
+}
+
+// staticAPIKeyProvider 静态配置的API key
+type staticAPIKeyProvider map[string]APIKey
+
+// NewStaticAPIKeyProvider 新建静态API key来源
+func NewStaticAPIKeyProvider(keys []APIKey) APIKeyProvider {
+	p := make(staticAPIKeyProvider, len(keys))
+	for _, key := range keys {
+		p[key.Key] = key
+	}
+	return p
+}
+
+// APIKey implements APIKeyProvider
+func (p staticAPIKeyProvider) APIKey(_ context.Context, key string) (*APIKey, error) {
+	if v, ok := p[key]; ok {
+		return &v, nil
+	}
+	return nil, nil
+}
+
+// apiKeyAuth API key鉴权
+type apiKeyAuth struct {
+	provider APIKeyProvider
+	sources  []credentialSource
+	excludes routeMatcher
+}
+
+// newAPIKeyAuth 根据配置构建API key鉴权
+func newAPIKeyAuth(config *Config) (*apiKeyAuth, error) {
+	excludes, err := newRouteMatcher("APIKeyExcludeRoutes", config.APIKeyExcludeRoutes)
+	if err != nil {
+		return nil, err
+	}
+	lookup := config.APIKeyLookup
+	if lookup == "" {
+		lookup = "header:" + HeaderXAPIKey
+	}
+	sources, err := parseCredentialLookup("APIKeyLookup", lookup)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKeyAuth{
+		provider: apiKeyProvider(config),
+		sources:  sources,
+		excludes: excludes,
+	}, nil
+}
+
+// apiKeyProvider 返回自定义的API key来源，未设置时使用配置中的 APIKeys
+func apiKeyProvider(config *Config) APIKeyProvider {
+	if config.apiKeyProvider != nil {
+		return config.apiKeyProvider
+	}
+	return NewStaticAPIKeyProvider(config.APIKeys)
+}
+
+// skip implements authenticator
+func (a *apiKeyAuth) skip(ctx Context) bool {
+	return skipAuth(ctx, a.excludes)
+}
+
+// authenticate implements authenticator，key不存在返回401，API key来源出错返回500
+func (a *apiKeyAuth) authenticate(ctx *Context) *authError {
+	key := readCredential(ctx.Req(), a.sources)
+	if key == "" {
+		return newAuthError(http.StatusUnauthorized, errMissingCredential)
+	}
+	apiKey, err := a.provider.APIKey(ctx.Context(), key)
+	if err != nil {
+		ctx.Log.Warn("api key provider error", elog.FieldErr(err))
+		return newAuthError(http.StatusInternalServerError, err)
+	}
+	if apiKey == nil {
+		ctx.Log.Debug("api key auth fail, unknown key")
+		return newAuthError(http.StatusUnauthorized, errors.New("unknown api key"))
+	}
+	ctx.setAPIKey(apiKey)
+	return nil
+}
+
+// setAPIKey 记录鉴权通过的调用方，密钥不会写入上下文
+func (c *Context) setAPIKey(apiKey *APIKey) {
+	v := *apiKey
+	v.Secret = ""
+	c.SetAttribute(apiKeyAttribute, v)
+	c.setIdentity(Identity{Type: IdentityAPIKey, Subject: v.ID, Roles: v.Roles})
+	c.AddLogFields(elog.String("apikey", v.ID))
+}
@@ -0,0 +1,136 @@
+package eref_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ego-plugin/server/eref"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testAPIKeys = []map[string]interface{}{
+	{"ID": "billing", "Key": "key-billing", "Secret": "secret-billing", "Roles": []string{"billing"}},
+}
+
+func TestAPIKey(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableAPIKey": true,
+		"APIKeys":      testAPIKeys,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	ws.Route(ws.GET("/key").To(eref.RouteContext(func(ctx eref.Context) {
+		key, _ := ctx.APIKey()
+		_ = ctx.WriteEntity(key)
+	})))
+	s.Add(ws)
+
+	var identity eref.Identity
+	s.GET("/api/me").Header(eref.HeaderXAPIKey, "key-billing").Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&identity)
+	if identity.Type != eref.IdentityAPIKey || identity.Subject != "billing" || len(identity.Roles) != 1 {
+		t.Errorf("identity = %+v", identity)
+	}
+	// 密钥不会写入上下文
+	var key eref.APIKey
+	s.GET("/api/key").Header(eref.HeaderXAPIKey, "key-billing").Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&key)
+	if key.ID != "billing" || key.Secret != "" {
+		t.Errorf("api key = %+v", key)
+	}
+
+	s.GET("/api/me").Do().ExpectStatus(http.StatusUnauthorized)
+	s.GET("/api/me").Header(eref.HeaderXAPIKey, "unknown").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+// errAPIKeyProvider 查询API key失败
+type errAPIKeyProvider struct{}
+
+func (errAPIKeyProvider) APIKey(context.Context, string) (*eref.APIKey, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestAPIKeyProviderError(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableAPIKey": true}, eref.WithAPIKeyProvider(errAPIKeyProvider{}))
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	s.GET("/api/me").Header(eref.HeaderXAPIKey, "key-billing").Do().ExpectStatus(http.StatusInternalServerError)
+}
+
+func TestAuthModeAny(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"AuthMode":     eref.AuthModeAny,
+		"EnableJWT":    true,
+		"JWTSecret":    testJWTSecret,
+		"EnableAPIKey": true,
+		"APIKeys":      testAPIKeys,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	var identity eref.Identity
+	s.GET("/api/me").Header(eref.HeaderXAPIKey, "key-billing").Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&identity)
+	if identity.Type != eref.IdentityAPIKey {
+		t.Errorf("identity = %+v, want api key identity", identity)
+	}
+
+	// 两种凭证都有效时使用第一个鉴权方式的身份
+	token := signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	s.GET("/api/me").Header("Authorization", "Bearer "+token).Header(eref.HeaderXAPIKey, "key-billing").Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&identity)
+	if identity.Type != eref.IdentityJWT || identity.Subject != "u1" {
+		t.Errorf("identity = %+v, want jwt identity", identity)
+	}
+
+	// 无效的token不影响后面的鉴权方式
+	s.GET("/api/me").Header("Authorization", "Bearer invalid").Header(eref.HeaderXAPIKey, "key-billing").Do().
+		ExpectStatus(http.StatusOK)
+
+	s.GET("/api/me").Do().
+		ExpectStatus(http.StatusUnauthorized).
+		ExpectHeader("WWW-Authenticate", "Bearer")
+	// 返回携带了凭证的鉴权方式的错误
+	s.GET("/api/me").Header(eref.HeaderXAPIKey, "unknown").Do().
+		ExpectStatus(http.StatusUnauthorized).
+		ExpectHeader("WWW-Authenticate", "")
+}
+
+func TestAuthModeAnyExcludedRoute(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"AuthMode":         eref.AuthModeAny,
+		"EnableJWT":        true,
+		"JWTSecret":        testJWTSecret,
+		"JWTExcludeRoutes": []string{"/api/partner"},
+		"EnableAPIKey":     true,
+		"APIKeys":          testAPIKeys,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/partner")
+	identityRoute(ws, "/public", eref.Public())
+	s.Add(ws)
+
+	// 只排除了JWT鉴权，仍然需要API key
+	s.GET("/api/partner").Do().ExpectStatus(http.StatusUnauthorized)
+	token := signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	s.GET("/api/partner").Header("Authorization", "Bearer "+token).Do().ExpectStatus(http.StatusUnauthorized)
+	s.GET("/api/partner").Header(eref.HeaderXAPIKey, "key-billing").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/public").Do().ExpectStatus(http.StatusOK)
+
+	paths := s.Component.OpenAPI().Paths
+	if security := paths["/api/partner"]["get"].Security; len(security) != 1 || security[0]["apiKeyAuth"] == nil {
+		t.Errorf("/api/partner security = %v, want apiKeyAuth only", security)
+	}
+	if security := paths["/api/public"]["get"].Security; len(security) != 0 {
+		t.Errorf("/api/public security = %v, want none", security)
+	}
+}
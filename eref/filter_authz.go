@@ -0,0 +1,237 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// 路由权限元数据的key，通过 restful.RouteBuilder.Metadata 或者 RequireRoles 等方法设置
+const (
+	MetadataRoles  = "eref.roles"  // 需要的角色，[]string 或逗号分隔的 string，命中任意一个即可
+	MetadataScopes = "eref.scopes" // 需要的授权范围，[]string 或逗号分隔的 string，需要全部满足
+	MetadataPolicy = "eref.policy" // 自定义策略名，由 PolicyEngine 解释
+	MetadataPublic = "eref.public" // 公开路由，不需要身份
+)
+
+// 未声明权限的路由的默认策略
+const (
+	AuthzDefaultAllow         = "allow"         // 放行
+	AuthzDefaultAuthenticated = "authenticated" // 需要经过鉴权
+	AuthzDefaultDeny          = "deny"          // 拒绝
+)
+
+// RequireRoles 路由需要任意一个角色，例如 ws.GET("/users").Do(eref.RequireRoles("admin"))
+func RequireRoles(roles ...string) func(*restful.RouteBuilder) {
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(MetadataRoles, roles)
+	}
+}
+
+// RequireScopes 路由需要全部授权范围
+func RequireScopes(scopes ...string) func(*restful.RouteBuilder) {
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(MetadataScopes, scopes)
+	}
+}
+
+// RequirePolicy 路由需要满足自定义策略
+func RequirePolicy(name string) func(*restful.RouteBuilder) {
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(MetadataPolicy, name)
+	}
+}
+
+// Public 公开路由，跳过鉴权和授权，不受 AuthzDefaultPolicy 影响
+func Public() func(*restful.RouteBuilder) {
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(MetadataPublic, true)
+	}
+}
+
+// Requirement 路由的权限要求
+type Requirement struct {
+	Roles  []string // 命中任意一个即可
+	Scopes []string // 需要全部满足
+	Policy string   // 自定义策略名
+}
+
+// Empty 是否没有声明任何权限要求
+func (r Requirement) Empty() bool {
+	return len(r.Roles) == 0 && len(r.Scopes) == 0 && r.Policy == ""
+}
+
+// PolicyEngine 授权策略，可以通过 WithPolicyEngine 接入 casbin、opa 等
+type PolicyEngine interface {
+	// Authorize 判断身份是否满足路由的权限要求
+	Authorize(ctx Context, identity Identity, requirement Requirement) (bool, error)
+}
+
+// rbacPolicyEngine 内置的RBAC策略
+type rbacPolicyEngine struct{}
+
+// NewRBACPolicyEngine 新建内置的RBAC策略，拥有任意一个角色并且拥有全部授权范围时放行，不支持自定义策略
+func NewRBACPolicyEngine() PolicyEngine {
+	return rbacPolicyEngine{}
+}
+
+// Authorize implements PolicyEngine
+func (rbacPolicyEngine) Authorize(_ Context, identity Identity, requirement Requirement) (bool, error) {
+	if requirement.Policy != "" {
+		return false, fmt.Errorf("unknown policy %q", requirement.Policy)
+	}
+	if len(requirement.Roles) > 0 {
+		matched := false
+		for _, role := range requirement.Roles {
+			if containsString(identity.Roles, role) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	for _, scope := range requirement.Scopes {
+		if !containsString(identity.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// routeRequirement 从路由元数据中解析权限要求
+func routeRequirement(metadata map[string]interface{}) (Requirement, bool) {
+	public, _ := metadata[MetadataPublic].(bool)
+	policy, _ := metadata[MetadataPolicy].(string)
+	return Requirement{
+		Roles:  metadataStrings(metadata[MetadataRoles]),
+		Scopes: metadataStrings(metadata[MetadataScopes]),
+		Policy: policy,
+	}, public
+}
+
+func metadataStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// authorizer 授权
+type authorizer struct {
+	engine        PolicyEngine
+	defaultPolicy string
+}
+
+// newAuthorizer 根据配置构建授权
+func newAuthorizer(config *Config) (*authorizer, error) {
+	a := &authorizer{
+		engine:        config.policyEngine,
+		defaultPolicy: config.AuthzDefaultPolicy,
+	}
+	if a.engine == nil {
+		a.engine = NewRBACPolicyEngine()
+	}
+	switch a.defaultPolicy {
+	case "":
+		a.defaultPolicy = AuthzDefaultAllow
+	case AuthzDefaultAllow, AuthzDefaultAuthenticated, AuthzDefaultDeny:
+	default:
+		return nil, fmt.Errorf("invalid AuthzDefaultPolicy %q", a.defaultPolicy)
+	}
+	return a, nil
+}
+
+// authzMiddleware 授权中间件，没有身份返回401，权限不足返回403
+func authzMiddleware(a *authorizer) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		route := ctx.SelectedRoute()
+		if route == nil {
+			ctx.ProcessFilter()
+			return
+		}
+		requirement, public := routeRequirement(route.Metadata())
+		if public {
+			ctx.ProcessFilter()
+			return
+		}
+		if requirement.Empty() {
+			switch a.defaultPolicy {
+			case AuthzDefaultAllow:
+				ctx.ProcessFilter()
+				return
+			case AuthzDefaultDeny:
+				ctx.Log.Debug("authz deny, route has no requirement")
+				_ = ctx.WriteErrorString(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+		}
+		identity, ok := ctx.Identity()
+		if !ok {
+			_ = ctx.WriteErrorString(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		if !requirement.Empty() {
+			allowed, err := a.engine.Authorize(ctx.Context, identity, requirement)
+			if err != nil {
+				ctx.Log.Warn("authz policy error", elog.FieldErr(err))
+				_ = ctx.WriteErrorString(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			if !allowed {
+				ctx.Log.Debug("authz deny", elog.String("subject", identity.Subject), elog.Any("roles", identity.Roles))
+				_ = ctx.WriteErrorString(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+		}
+		ctx.ProcessFilter()
+	})
+}
+
+// RoutePermission 路由的权限声明，用于审计
+type RoutePermission struct {
+	Method string   `json:"method"`
+	Path   string   `json:"path"`
+	Public bool     `json:"public"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	Policy string   `json:"policy,omitempty"`
+}
+
+// RoutePermissions 返回所有路由的权限声明，按路径、方法排序
+func (c *Component) RoutePermissions() []RoutePermission {
+	var list []RoutePermission
+	for _, ws := range c.container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			requirement, public := routeRequirement(route.Metadata)
+			list = append(list, RoutePermission{
+				Method: route.Method,
+				Path:   route.Path,
+				Public: public,
+				Roles:  requirement.Roles,
+				Scopes: requirement.Scopes,
+				Policy: requirement.Policy,
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	return list
+}
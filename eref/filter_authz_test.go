@@ -0,0 +1,75 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuthzRolesAndScopes(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":          true,
+		"JWTSecret":          testJWTSecret,
+		"EnableAuthz":        true,
+		"AuthzDefaultPolicy": eref.AuthzDefaultDeny,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/admin", eref.RequireRoles("admin", "ops"))
+	identityRoute(ws, "/reports", eref.RequireScopes("read", "report"))
+	identityRoute(ws, "/undeclared")
+	identityRoute(ws, "/public", eref.Public())
+	s.Add(ws)
+
+	token := func(roles []string, scope string) string {
+		return "Bearer " + signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix(), "roles": roles, "scope": scope})
+	}
+	admin := token([]string{"ops"}, "read")
+	user := token([]string{"user"}, "read report")
+
+	// 命中任意一个角色即可
+	s.GET("/api/admin").Header("Authorization", admin).Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/admin").Header("Authorization", user).Do().ExpectStatus(http.StatusForbidden)
+	// 需要全部授权范围
+	s.GET("/api/reports").Header("Authorization", user).Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/reports").Header("Authorization", admin).Do().ExpectStatus(http.StatusForbidden)
+	// 未声明权限的路由按默认策略拒绝
+	s.GET("/api/undeclared").Header("Authorization", admin).Do().ExpectStatus(http.StatusForbidden)
+	s.GET("/api/public").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestAuthzAuthenticated(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableAuthz":        true,
+		"AuthzDefaultPolicy": eref.AuthzDefaultAuthenticated,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	// 没有开启鉴权中间件时没有身份
+	s.GET("/api/me").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+// ownerPolicyEngine 只允许访问自己的资源
+type ownerPolicyEngine struct{}
+
+func (ownerPolicyEngine) Authorize(ctx eref.Context, identity eref.Identity, requirement eref.Requirement) (bool, error) {
+	return requirement.Policy == "owner" && ctx.PathParameter("id") == identity.Subject, nil
+}
+
+func TestAuthzPolicyEngine(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":   true,
+		"JWTSecret":   testJWTSecret,
+		"EnableAuthz": true,
+	}, eref.WithPolicyEngine(ownerPolicyEngine{}))
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/users/{id}", eref.RequirePolicy("owner"))
+	s.Add(ws)
+
+	token := "Bearer " + signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	s.GET("/api/users/u1").Header("Authorization", token).Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/users/u2").Header("Authorization", token).Do().ExpectStatus(http.StatusForbidden)
+}
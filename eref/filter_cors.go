@@ -0,0 +1,211 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerOrigin                        = "Origin"
+	headerVary                          = "Vary"
+	headerAccessControlRequestMethod    = "Access-Control-Request-Method"
+	headerAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	headerAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	headerAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// cors 跨域配置
+type cors struct {
+	allowAll         bool
+	origins          map[string]struct{} // 精确匹配的origin
+	originPatterns   []*regexp.Regexp    // 通配、正则匹配的origin
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+	container        *restful.Container
+}
+
+// newCors 根据配置构建跨域中间件配置
+// CORSAllowOrigins 支持 * 全部放行、https://*.example.com 通配以及 ~ 开头的正则
+func newCors(config *Config, container *restful.Container) (*cors, error) {
+	c := &cors{
+		origins:          make(map[string]struct{}),
+		allowMethods:     strings.ToUpper(strings.Join(config.CORSAllowMethods, ", ")),
+		allowHeaders:     strings.Join(config.CORSAllowHeaders, ", "),
+		exposeHeaders:    strings.Join(config.CORSExposeHeaders, ", "),
+		allowCredentials: config.CORSAllowCredentials,
+		container:        container,
+	}
+	if config.CORSMaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.CORSMaxAge.Seconds()))
+	}
+	for _, origin := range config.CORSAllowOrigins {
+		origin = strings.TrimSpace(origin)
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case strings.HasPrefix(origin, "~"):
+			reg, err := regexp.Compile(origin[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORSAllowOrigins %q, %w", origin, err)
+			}
+			c.originPatterns = append(c.originPatterns, reg)
+		case strings.Contains(origin, "*"):
+			reg, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[^/]*`) + "$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORSAllowOrigins %q, %w", origin, err)
+			}
+			c.originPatterns = append(c.originPatterns, reg)
+		default:
+			c.origins[strings.ToLower(origin)] = struct{}{}
+		}
+	}
+	return c, nil
+}
+
+// allowOrigin 判断origin是否允许跨域
+func (c *cors) allowOrigin(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := c.origins[origin]; ok {
+		return true
+	}
+	for _, reg := range c.originPatterns {
+		if reg.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware 跨域中间件，自动应答已注册路由的预检请求
+func corsMiddleware(c *cors) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		origin := ctx.HeaderParameter(headerOrigin)
+		if origin == "" {
+			ctx.ProcessFilter()
+			return
+		}
+		ctx.Response.AddHeader(headerVary, headerOrigin)
+		if !c.allowOrigin(origin) {
+			ctx.ProcessFilter()
+			return
+		}
+		// 预检请求
+		if ctx.Req().Method == http.MethodOptions && ctx.HeaderParameter(headerAccessControlRequestMethod) != "" {
+			c.preflight(ctx, origin)
+			return
+		}
+		c.setOriginHeaders(ctx.Response, origin)
+		if c.exposeHeaders != "" {
+			ctx.Response.AddHeader(headerAccessControlExposeHeaders, c.exposeHeaders)
+		}
+		ctx.ProcessFilter()
+	})
+}
+
+func (c *cors) setOriginHeaders(resp *restful.Response, origin string) {
+	// 带凭证时不能返回 *，统一返回请求的 origin
+	if c.allowAll && !c.allowCredentials {
+		resp.AddHeader(headerAccessControlAllowOrigin, "*")
+	} else {
+		resp.AddHeader(headerAccessControlAllowOrigin, origin)
+	}
+	if c.allowCredentials {
+		resp.AddHeader(headerAccessControlAllowCredentials, "true")
+	}
+}
+
+// preflight 应答预检请求，路由不存在或者方法不允许时交给后续处理返回404、405
+func (c *cors) preflight(ctx FilterContext, origin string) {
+	requestMethod := strings.ToUpper(ctx.HeaderParameter(headerAccessControlRequestMethod))
+	methods := routeMethods(c.container, ctx.Req().URL.Path)
+	if !containsString(methods, requestMethod) {
+		ctx.ProcessFilter()
+		return
+	}
+	allowMethods := c.allowMethods
+	if allowMethods == "" {
+		allowMethods = strings.Join(methods, ", ")
+	} else if !containsString(strings.Split(strings.ReplaceAll(allowMethods, " ", ""), ","), requestMethod) {
+		ctx.ProcessFilter()
+		return
+	}
+	allowHeaders := c.allowHeaders
+	if allowHeaders == "" {
+		// 未配置时回显请求的header
+		allowHeaders = ctx.HeaderParameter(headerAccessControlRequestHeaders)
+	}
+	ctx.Response.AddHeader(headerVary, headerAccessControlRequestMethod)
+	ctx.Response.AddHeader(headerVary, headerAccessControlRequestHeaders)
+	c.setOriginHeaders(ctx.Response, origin)
+	ctx.Response.AddHeader(headerAccessControlAllowMethods, allowMethods)
+	if allowHeaders != "" {
+		ctx.Response.AddHeader(headerAccessControlAllowHeaders, allowHeaders)
+	}
+	if c.maxAge != "" {
+		ctx.Response.AddHeader(headerAccessControlMaxAge, c.maxAge)
+	}
+	ctx.Response.WriteHeader(http.StatusNoContent)
+}
+
+// routeMethods 返回路径上已注册的方法
+func routeMethods(container *restful.Container, path string) []string {
+	set := make(map[string]struct{})
+	for _, ws := range container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			if matchRoutePath(route.Path, path) {
+				set[route.Method] = struct{}{}
+			}
+		}
+	}
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchRoutePath 判断请求路径是否匹配路由模板，{param} 匹配一段，{param:*} 匹配剩余部分
+func matchRoutePath(template, path string) bool {
+	tokens := strings.Split(strings.Trim(template, "/"), "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, token := range tokens {
+		if strings.HasPrefix(token, "{") && strings.HasSuffix(token, ":*}") {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if strings.HasPrefix(token, "{") && strings.HasSuffix(token, "}") {
+			continue
+		}
+		if token != parts[i] {
+			return false
+		}
+	}
+	return len(tokens) == len(parts)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
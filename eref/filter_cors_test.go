@@ -0,0 +1,123 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"net/http"
+	"testing"
+)
+
+// corsServer 开启跨域和JWT鉴权的测试服务
+func corsServer(t *testing.T, conf map[string]interface{}) *ereftest.Server {
+	t.Helper()
+	conf["EnableCORS"] = true
+	conf["EnableJWT"] = true
+	conf["JWTSecret"] = testJWTSecret
+	s := loadServer(t, conf)
+	ws := eref.NewRoute("/api/cors")
+	identityRoute(ws, "/items/{id}")
+	ws.Route(ws.POST("/items/{id}").To(eref.RouteContext(func(ctx eref.Context) {})))
+	s.Add(ws)
+	return s
+}
+
+// preflight 发送预检请求
+func preflight(s *ereftest.Server, path, origin, method string) *ereftest.Response {
+	return s.NewRequest(http.MethodOptions, path).
+		Header("Origin", origin).
+		Header("Access-Control-Request-Method", method).
+		Header("Access-Control-Request-Headers", "Authorization, Content-Type").
+		Do()
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := corsServer(t, map[string]interface{}{
+		"CORSAllowOrigins":     []string{"https://app.example.com"},
+		"CORSAllowCredentials": true,
+		"CORSMaxAge":           "10m",
+	})
+
+	// 预检请求不需要鉴权，方法默认为路由上注册的方法，header 默认回显
+	preflight(s, "/api/cors/items/1", "https://app.example.com", http.MethodPost).
+		ExpectStatus(http.StatusNoContent).
+		ExpectHeader("Access-Control-Allow-Origin", "https://app.example.com").
+		ExpectHeader("Access-Control-Allow-Methods", "GET, POST").
+		ExpectHeader("Access-Control-Allow-Headers", "Authorization, Content-Type").
+		ExpectHeader("Access-Control-Allow-Credentials", "true").
+		ExpectHeader("Access-Control-Max-Age", "600")
+
+	// 未注册的方法、路径不应答预检
+	for _, res := range []*ereftest.Response{
+		preflight(s, "/api/cors/items/1", "https://app.example.com", http.MethodDelete),
+		preflight(s, "/api/cors/missing", "https://app.example.com", http.MethodGet),
+	} {
+		if res.Status() == http.StatusNoContent || res.Header("Access-Control-Allow-Methods") != "" {
+			t.Errorf("preflight answered with status %d", res.Status())
+		}
+	}
+	// 不允许的origin
+	res := preflight(s, "/api/cors/items/1", "https://evil.example.com", http.MethodPost)
+	if res.Status() == http.StatusNoContent || res.Header("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight from disallowed origin answered with status %d", res.Status())
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	s := corsServer(t, map[string]interface{}{
+		"CORSAllowOrigins":  []string{"https://*.example.com", `~^https://[a-z]+\.example\.org$`},
+		"CORSExposeHeaders": []string{"X-Request-Id"},
+	})
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":   true,
+		"https://APP.example.com":   true,
+		"https://admin.example.org": true,
+		"https://example.com":       false,
+		"https://a.b.example.org":   false,
+		"http://app.example.com":    false,
+	} {
+		res := s.GET("/api/cors/items/1").Header("Origin", origin).Do()
+		got := res.Header("Access-Control-Allow-Origin")
+		if allowed && got != origin || !allowed && got != "" {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q, allowed = %v", origin, got, allowed)
+		}
+		if res.Header("Vary") != "Origin" {
+			t.Errorf("origin %s: Vary = %q", origin, res.Header("Vary"))
+		}
+	}
+	// 鉴权失败的响应同样带有跨域header，浏览器才能读取错误
+	s.GET("/api/cors/items/1").Header("Origin", "https://app.example.com").Do().
+		ExpectStatus(http.StatusUnauthorized).
+		ExpectHeader("Access-Control-Allow-Origin", "https://app.example.com").
+		ExpectHeader("Access-Control-Expose-Headers", "X-Request-Id")
+	// 非跨域请求不受影响
+	if got := s.GET("/api/cors/items/1").Do().ExpectStatus(http.StatusUnauthorized).Header("Vary"); got != "" {
+		t.Errorf("same origin Vary = %q", got)
+	}
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	s := corsServer(t, map[string]interface{}{
+		"CORSAllowOrigins": []string{"*"},
+		"CORSAllowMethods": []string{"get"},
+		"CORSAllowHeaders": []string{"Authorization"},
+	})
+	preflight(s, "/api/cors/items/1", "https://any.example.net", http.MethodGet).
+		ExpectStatus(http.StatusNoContent).
+		ExpectHeader("Access-Control-Allow-Origin", "*").
+		ExpectHeader("Access-Control-Allow-Methods", "GET").
+		ExpectHeader("Access-Control-Allow-Headers", "Authorization")
+	// 配置了允许的方法时，其他方法不应答预检
+	if res := preflight(s, "/api/cors/items/1", "https://any.example.net", http.MethodPost); res.Status() == http.StatusNoContent {
+		t.Error("preflight answered for method not in CORSAllowMethods")
+	}
+
+	// 带凭证时返回请求的origin
+	s = corsServer(t, map[string]interface{}{
+		"CORSAllowOrigins":     []string{"*"},
+		"CORSAllowCredentials": true,
+	})
+	s.GET("/api/cors/items/1").Header("Origin", "https://any.example.net").Do().
+		ExpectHeader("Access-Control-Allow-Origin", "https://any.example.net").
+		ExpectHeader("Access-Control-Allow-Credentials", "true")
+}
@@ -0,0 +1,111 @@
+package eref
+
+import (
+	"compress/gzip"
+	"github.com/emicklei/go-restful/v3"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gzipMiddleware 压缩响应，SSE 等流式请求和 websocket 不压缩
+func gzipMiddleware(level int) (restful.FilterFunction, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+	pool := &sync.Pool{
+		New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		},
+	}
+	return Filter(func(ctx FilterContext) {
+		if !strings.Contains(ctx.Req().Header.Get(restful.HEADER_AcceptEncoding), restful.ENCODING_GZIP) ||
+			ctx.Req().Header.Get("Upgrade") != "" || isStreaming(ctx.Request) {
+			ctx.ProcessFilter()
+			return
+		}
+		w := &gzipWriter{ResponseWriter: ctx.Response.ResponseWriter, pool: pool}
+		ctx.Response.ResponseWriter = w
+		defer w.close()
+		ctx.ProcessFilter()
+	}), nil
+}
+
+// gzipWriter 第一次写入时根据响应头决定是否压缩
+type gzipWriter struct {
+	http.ResponseWriter
+	pool    *sync.Pool
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	w.decide(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.decide(http.StatusOK)
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide 没有响应体、已经编码或者 text/event-stream 时不压缩
+func (w *gzipWriter) decide(code int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	header := w.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		header.Get(restful.HEADER_ContentEncoding) != "" || isEventStream(header) {
+		return
+	}
+	header.Set(restful.HEADER_ContentEncoding, restful.ENCODING_GZIP)
+	header.Add("Vary", restful.HEADER_AcceptEncoding)
+	header.Del("Content-Length")
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+// Flush implements http.Flusher
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements http.CloseNotifier
+func (w *gzipWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	// 不支持时返回永远不会关闭的 channel
+	return make(chan bool)
+}
+
+// Unwrap 用于 http.ResponseController
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close 写入gzip结尾并放回池中
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
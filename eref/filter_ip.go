@@ -0,0 +1,133 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// IPRule 路由IP访问规则
+type IPRule struct {
+	Allow []string // 允许的CIDR或IP，为空时不限制
+	Deny  []string // 拒绝的CIDR或IP，优先于 Allow
+}
+
+// ipRule 解析后的IP访问规则
+type ipRule struct {
+	allow ipNets
+	deny  ipNets
+}
+
+// ipRouteRule 路由IP访问规则，pattern 语法同 routeMatcher
+type ipRouteRule struct {
+	pattern string
+	rule    ipRule
+}
+
+// ipRules 全局和路由的IP访问规则
+type ipRules struct {
+	global ipRule
+	routes []ipRouteRule
+}
+
+// newIPRules 根据配置构建IP访问规则
+func newIPRules(allow, deny []string, routes map[string]IPRule) (*ipRules, error) {
+	global, err := newIPRule("IPAllowList", "IPDenyList", IPRule{Allow: allow, Deny: deny})
+	if err != nil {
+		return nil, err
+	}
+	rules := &ipRules{global: global}
+	for pattern, rule := range routes {
+		if _, err := newRouteMatcher("IPRouteRules", []string{pattern}); err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("IPRouteRules %q", pattern)
+		r, err := newIPRule(name, name, rule)
+		if err != nil {
+			return nil, err
+		}
+		rules.routes = append(rules.routes, ipRouteRule{pattern: pattern, rule: r})
+	}
+	// 规则越长越具体，优先匹配
+	sort.Slice(rules.routes, func(i, j int) bool {
+		if len(rules.routes[i].pattern) != len(rules.routes[j].pattern) {
+			return len(rules.routes[i].pattern) > len(rules.routes[j].pattern)
+		}
+		return rules.routes[i].pattern < rules.routes[j].pattern
+	})
+	return rules, nil
+}
+
+func newIPRule(allowName, denyName string, rule IPRule) (ipRule, error) {
+	allow, err := parseIPNets(allowName, rule.Allow)
+	if err != nil {
+		return ipRule{}, err
+	}
+	deny, err := parseIPNets(denyName, rule.Deny)
+	if err != nil {
+		return ipRule{}, err
+	}
+	return ipRule{allow: allow, deny: deny}, nil
+}
+
+// check 判断ip是否可以访问路由，不能访问时返回原因
+// 全局和路由的拒绝列表都会生效，路由配置了允许列表时替代全局允许列表
+func (r *ipRules) check(method, routePath, ip string) (bool, string) {
+	if r.global.deny.Contains(ip) {
+		return false, "in IPDenyList"
+	}
+	allow, name := r.global.allow, "IPAllowList"
+	for _, route := range r.routes {
+		if !matchRoute(route.pattern, method, routePath) {
+			continue
+		}
+		if route.rule.deny.Contains(ip) {
+			return false, fmt.Sprintf("in IPRouteRules %q deny list", route.pattern)
+		}
+		if len(route.rule.allow) > 0 {
+			allow, name = route.rule.allow, fmt.Sprintf("IPRouteRules %q allow list", route.pattern)
+		}
+		break
+	}
+	if len(allow) > 0 && !allow.Contains(ip) {
+		return false, "not in " + name
+	}
+	return true, ""
+}
+
+// ipFilter IP访问控制，规则可以热更新
+type ipFilter struct {
+	rules atomic.Value // *ipRules
+}
+
+// newIPFilter 根据配置构建IP访问控制
+func newIPFilter(config *Config) (*ipFilter, error) {
+	rules, err := newIPRules(config.IPAllowList, config.IPDenyList, config.IPRouteRules)
+	if err != nil {
+		return nil, err
+	}
+	f := &ipFilter{}
+	f.rules.Store(rules)
+	return f, nil
+}
+
+// update 替换规则
+func (f *ipFilter) update(rules *ipRules) {
+	f.rules.Store(rules)
+}
+
+// ipFilterMiddleware IP访问控制中间件，不允许访问时返回403
+func ipFilterMiddleware(f *ipFilter) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		rules := f.rules.Load().(*ipRules)
+		if ok, reason := rules.check(ctx.Req().Method, ctx.SelectedRoutePath(), ctx.ClientIP()); !ok {
+			ctx.Log.Info("ip filter deny", elog.String("reason", reason))
+			_ = ctx.WriteErrorString(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		ctx.ProcessFilter()
+	})
+}
@@ -0,0 +1,82 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"net/http"
+	"testing"
+)
+
+// ipRoutes 返回客户端IP的路由
+func ipRoutes(s *ereftest.Server) {
+	ws := eref.NewRoute("/api")
+	for _, path := range []string{"/ip", "/internal/ip"} {
+		ws.Route(ws.GET(path).To(eref.RouteContext(func(ctx eref.Context) {
+			_, _ = ctx.Write([]byte(ctx.ClientIP()))
+		})))
+	}
+	s.Add(ws)
+}
+
+func TestIPFilter(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableIPFilter": true,
+		"IPAllowList":    []string{"192.0.2.0/24"},
+		"IPDenyList":     []string{"192.0.2.66"},
+		"IPRouteRules": map[string]interface{}{
+			"/api/internal/*": map[string]interface{}{"Allow": []string{"10.0.0.0/8"}},
+		},
+		"EnableOpenAPI": true,
+	})
+	ipRoutes(s)
+
+	s.GET("/api/ip").RemoteAddr("192.0.2.1:1234").Do().ExpectStatus(http.StatusOK).ExpectBody("192.0.2.1")
+	s.GET("/api/ip").RemoteAddr("192.0.2.66:1234").Do().ExpectStatus(http.StatusForbidden)
+	s.GET("/api/ip").RemoteAddr("198.51.100.1:1234").Do().ExpectStatus(http.StatusForbidden)
+	// 路由的允许列表替代全局允许列表
+	s.GET("/api/internal/ip").RemoteAddr("10.1.2.3:1234").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/internal/ip").RemoteAddr("192.0.2.1:1234").Do().ExpectStatus(http.StatusForbidden)
+	// 内置的文档接口同样受IP访问控制
+	s.GET("/openapi.json").RemoteAddr("198.51.100.1:1234").Do().ExpectStatus(http.StatusForbidden)
+	s.GET("/openapi.json").RemoteAddr("192.0.2.1:1234").Do().ExpectStatus(http.StatusOK)
+	// 没有配置可信代理时不读取 X-Forwarded-For
+	s.GET("/api/ip").RemoteAddr("198.51.100.1:1234").Header("X-Forwarded-For", "192.0.2.1").Do().
+		ExpectStatus(http.StatusForbidden)
+}
+
+func TestIPFilterTrustedProxies(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableIPFilter": true,
+		"IPAllowList":    []string{"192.0.2.0/24"},
+		"TrustedProxies": []string{"10.0.0.0/8"},
+	})
+	ipRoutes(s)
+
+	const proxy = "10.0.0.1:1234"
+	s.GET("/api/ip").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.1").Do().
+		ExpectStatus(http.StatusOK).ExpectBody("192.0.2.1")
+	// 多级可信代理从右往左跳过
+	s.GET("/api/ip").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.1, 10.0.0.2").Do().
+		ExpectStatus(http.StatusOK).ExpectBody("192.0.2.1")
+	// 客户端伪造的最左边的地址不生效
+	s.GET("/api/ip").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.1, 198.51.100.7").Do().
+		ExpectStatus(http.StatusForbidden)
+	s.GET("/api/ip").RemoteAddr(proxy).Header("X-Real-IP", "192.0.2.9").Do().
+		ExpectStatus(http.StatusOK).ExpectBody("192.0.2.9")
+	s.GET("/api/ip").RemoteAddr(proxy).Header("Forwarded", `for="192.0.2.10:4711"`).Do().
+		ExpectStatus(http.StatusOK).ExpectBody("192.0.2.10")
+	// 不可信的来源不读取转发header
+	s.GET("/api/ip").RemoteAddr("198.51.100.1:1234").Header("X-Forwarded-For", "192.0.2.1").Do().
+		ExpectStatus(http.StatusForbidden)
+}
+
+func TestClientIPTrustAllProxies(t *testing.T) {
+	// 没有开启IP访问控制、也没有配置可信代理时信任全部代理
+	s := ereftest.New(t)
+	ipRoutes(s)
+
+	s.GET("/api/ip").RemoteAddr("198.51.100.1:1234").Header("X-Forwarded-For", "192.0.2.1, 10.0.0.2").Do().
+		ExpectStatus(http.StatusOK).ExpectBody("192.0.2.1")
+	s.GET("/api/ip").RemoteAddr("198.51.100.1:1234").Do().
+		ExpectStatus(http.StatusOK).ExpectBody("198.51.100.1")
+}
@@ -0,0 +1,338 @@
+package eref
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+)
+
+// jwtClaimsAttribute JWT声明在 restful.Request 中的属性名
+const jwtClaimsAttribute = "jwt_claims"
+
+type jwtClaimsKey struct{}
+
+// defaultJWTAlgorithms 默认允许的签名算法，实际可用的算法还取决于配置的密钥类型
+var defaultJWTAlgorithms = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"ES256", "ES384", "ES512",
+}
+
+// JWTClaims 校验通过的JWT声明
+type JWTClaims jwt.MapClaims
+
+// Subject 返回 sub
+func (c JWTClaims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer 返回 iss
+func (c JWTClaims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience 返回 aud
+func (c JWTClaims) Audience() []string {
+	aud, _ := jwt.MapClaims(c).GetAudience()
+	return aud
+}
+
+// ExpiresAt 返回 exp，不存在时为零值
+func (c JWTClaims) ExpiresAt() time.Time {
+	return c.Time("exp")
+}
+
+// IssuedAt 返回 iat，不存在时为零值
+func (c JWTClaims) IssuedAt() time.Time {
+	return c.Time("iat")
+}
+
+// String 返回字符串类型的声明
+func (c JWTClaims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings 返回字符串数组类型的声明，单个字符串和空格分隔的字符串（如 scope）也会转换成数组
+func (c JWTClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// UserID 返回用户ID声明，数字类型的ID会转换成字符串
+func (c JWTClaims) UserID(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return ""
+}
+
+// Int64 返回数字类型的声明
+func (c JWTClaims) Int64(name string) int64 {
+	switch v := c[name].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+// Float64 返回数字类型的声明
+func (c JWTClaims) Float64(name string) float64 {
+	switch v := c[name].(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	}
+	return 0
+}
+
+// Bool 返回布尔类型的声明
+func (c JWTClaims) Bool(name string) bool {
+	b, _ := c[name].(bool)
+	return b
+}
+
+// Time 返回秒级时间戳类型的声明
+func (c JWTClaims) Time(name string) time.Time {
+	if _, ok := c[name]; !ok {
+		return time.Time{}
+	}
+	return time.Unix(c.Int64(name), 0)
+}
+
+// JWTClaimsFromContext 从context中获取JWT声明
+func JWTClaimsFromContext(ctx context.Context) (JWTClaims, bool) {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(JWTClaims)
+	return claims, ok
+}
+
+// WithJWTClaims 将JWT声明写入context
+func WithJWTClaims(ctx context.Context, claims JWTClaims) context.Context {
+	return context.WithValue(ctx, jwtClaimsKey{}, claims)
+}
+
+// jwtAuth JWT鉴权
+type jwtAuth struct {
+	parser      *jwt.Parser
+	issuers     []string
+	audiences   []string
+	keys        []interface{} // 静态密钥，HMAC为[]byte，RSA、ECDSA为公钥
+	jwks        *jwks
+	sources     []credentialSource
+	userIDClaim string
+	rolesClaim  string
+	scopesClaim string
+	excludes    routeMatcher
+}
+
+// newJWTAuth 根据配置构建JWT鉴权
+func newJWTAuth(config *Config) (*jwtAuth, error) {
+	algorithms := config.JWTAlgorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultJWTAlgorithms
+	}
+	for _, alg := range algorithms {
+		if jwt.GetSigningMethod(alg) == nil || alg == "none" {
+			return nil, fmt.Errorf("invalid JWTAlgorithms, unknown algorithm %q", alg)
+		}
+	}
+	excludes, err := newRouteMatcher("JWTExcludeRoutes", config.JWTExcludeRoutes)
+	if err != nil {
+		return nil, err
+	}
+	parserOptions := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithLeeway(config.JWTClockSkew), jwt.WithIssuedAt()}
+	if !config.JWTAllowMissingExp {
+		parserOptions = append(parserOptions, jwt.WithExpirationRequired())
+	}
+	a := &jwtAuth{
+		parser:      jwt.NewParser(parserOptions...),
+		issuers:     config.JWTIssuers,
+		audiences:   config.JWTAudiences,
+		userIDClaim: config.JWTUserIDClaim,
+		rolesClaim:  config.JWTRolesClaim,
+		scopesClaim: config.JWTScopesClaim,
+		excludes:    excludes,
+	}
+	if a.userIDClaim == "" {
+		a.userIDClaim = "sub"
+	}
+	if a.rolesClaim == "" {
+		a.rolesClaim = "roles"
+	}
+	if a.scopesClaim == "" {
+		a.scopesClaim = "scope"
+	}
+	if config.JWTSecret != "" {
+		a.keys = append(a.keys, []byte(config.JWTSecret))
+	}
+	for _, file := range config.JWTPublicKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWTPublicKeyFiles %q, %w", file, err)
+		}
+		key, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWTPublicKeyFiles %q, %w", file, err)
+		}
+		a.keys = append(a.keys, key)
+	}
+	if config.JWKSURL != "" {
+		a.jwks = newJWKS(config.JWKSURL, config.JWKSRefreshInterval)
+	}
+	if len(a.keys) == 0 && a.jwks == nil {
+		return nil, errors.New("invalid jwt config, one of JWTSecret, JWTPublicKeyFiles, JWKSURL is required")
+	}
+	lookup := config.JWTTokenLookup
+	if lookup == "" {
+		lookup = "header:" + headerAuthorization
+	}
+	if a.sources, err = parseCredentialLookup("JWTTokenLookup", lookup); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parse 校验token并返回声明
+func (a *jwtAuth) parse(ctx context.Context, token string) (JWTClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFunc(ctx)); err != nil {
+		return nil, err
+	}
+	if len(a.issuers) > 0 {
+		iss, _ := claims.GetIssuer()
+		if !containsString(a.issuers, iss) {
+			return nil, fmt.Errorf("%w, issuer %q not allowed", jwt.ErrTokenInvalidIssuer, iss)
+		}
+	}
+	if len(a.audiences) > 0 {
+		aud, _ := claims.GetAudience()
+		matched := false
+		for _, v := range aud {
+			if containsString(a.audiences, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%w, audience %v not allowed", jwt.ErrTokenInvalidAudience, []string(aud))
+		}
+	}
+	return JWTClaims(claims), nil
+}
+
+// keyFunc 根据签名算法和kid选择校验密钥，密钥类型必须和算法匹配，防止算法混淆攻击
+func (a *jwtAuth) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		candidates := a.keys
+		kid, _ := token.Header["kid"].(string)
+		if a.jwks != nil && (kid != "" || len(candidates) == 0) {
+			key, err := a.jwks.key(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+			candidates = []interface{}{key}
+		}
+		set := jwt.VerificationKeySet{}
+		for _, key := range candidates {
+			if keyMatchesMethod(key, token.Method) {
+				set.Keys = append(set.Keys, key)
+			}
+		}
+		if len(set.Keys) == 0 {
+			return nil, fmt.Errorf("no key for algorithm %q", token.Method.Alg())
+		}
+		return set, nil
+	}
+}
+
+func keyMatchesMethod(key interface{}, method jwt.SigningMethod) bool {
+	switch key.(type) {
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	}
+	return false
+}
+
+// skip implements authenticator
+func (a *jwtAuth) skip(ctx Context) bool {
+	return skipAuth(ctx, a.excludes)
+}
+
+// authenticate implements authenticator，校验通过后声明写入上下文，用户ID写入访问日志
+func (a *jwtAuth) authenticate(ctx *Context) *authError {
+	token := readCredential(ctx.Req(), a.sources)
+	if token == "" {
+		return &authError{status: http.StatusUnauthorized, challenge: "Bearer", err: errMissingCredential}
+	}
+	claims, err := a.parse(ctx.Context(), token)
+	if err != nil {
+		ctx.Log.Debug("jwt auth fail", elog.FieldErr(err))
+		return &authError{status: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`, err: err}
+	}
+	ctx.SetAttribute(jwtClaimsAttribute, claims)
+	ctx.Request.Request = ctx.Req().WithContext(WithJWTClaims(ctx.Req().Context(), claims))
+	uid := claims.UserID(a.userIDClaim)
+	if uid != "" {
+		ctx.AddLogFields(fieldUID(uid))
+	}
+	ctx.setIdentity(Identity{
+		Type:    IdentityJWT,
+		Subject: uid,
+		Roles:   claims.Strings(a.rolesClaim),
+		Scopes:  claims.Strings(a.scopesClaim),
+	})
+	return nil
+}
+
+// fieldUID 用户ID日志字段
+func fieldUID(uid string) elog.Field {
+	return elog.String("uid", uid)
+}
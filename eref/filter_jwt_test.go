@@ -0,0 +1,84 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testJWTSecret = "eref-test-secret"
+
+// signJWT 使用 HS256 签发token
+func signJWT(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("sign jwt: %v", err)
+	}
+	return token
+}
+
+func TestJWT(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":        true,
+		"JWTSecret":        testJWTSecret,
+		"JWTIssuers":       []string{"eref"},
+		"JWTExcludeRoutes": []string{"GET./api/health"},
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	identityRoute(ws, "/health")
+	identityRoute(ws, "/public", eref.Public())
+	s.Add(ws)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := signJWT(t, jwt.MapClaims{"sub": "u1", "iss": "eref", "exp": exp, "roles": []string{"admin"}, "scope": "read write"})
+	var identity eref.Identity
+	s.GET("/api/me").Header("Authorization", "Bearer "+valid).Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&identity)
+	if identity.Type != eref.IdentityJWT || identity.Subject != "u1" || len(identity.Roles) != 1 || len(identity.Scopes) != 2 {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	s.GET("/api/me").Do().
+		ExpectStatus(http.StatusUnauthorized).
+		ExpectHeader("WWW-Authenticate", "Bearer")
+
+	invalid := map[string]string{
+		"expired":      signJWT(t, jwt.MapClaims{"sub": "u1", "iss": "eref", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"missing exp":  signJWT(t, jwt.MapClaims{"sub": "u1", "iss": "eref"}),
+		"wrong issuer": signJWT(t, jwt.MapClaims{"sub": "u1", "iss": "other", "exp": exp}),
+		"malformed":    "not-a-token",
+	}
+	for name, token := range invalid {
+		t.Run(name, func(t *testing.T) {
+			s.GET("/api/me").Header("Authorization", "Bearer "+token).Do().
+				ExpectStatus(http.StatusUnauthorized).
+				ExpectHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+		})
+	}
+
+	// 排除的路由和公开路由不需要token
+	s.GET("/api/health").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/public").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestJWTAllowMissingExp(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":          true,
+		"JWTSecret":          testJWTSecret,
+		"JWTAllowMissingExp": true,
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	s.GET("/api/me").Header("Authorization", "Bearer "+signJWT(t, jwt.MapClaims{"sub": "u1"})).Do().
+		ExpectStatus(http.StatusOK)
+	// 带了 exp 仍然校验是否过期
+	s.GET("/api/me").Header("Authorization", "Bearer "+signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(-time.Hour).Unix()})).Do().
+		ExpectStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,176 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderXRequestPriority 默认的请求优先级header
+const HeaderXRequestPriority = "X-Request-Priority"
+
+// 请求优先级，数值越小优先级越高
+const (
+	PriorityCritical = iota
+	PriorityHigh
+	PriorityNormal
+	PriorityLow
+)
+
+// lowPriorityStaticRatio 低优先级请求在并发达到上限的该比例时就拒绝，给高优先级请求留出余量
+const lowPriorityStaticRatio = 0.8
+
+// adaptivePriorityRatio 自适应限流时各优先级可以使用的并发比例，critical 不参与自适应限流
+var adaptivePriorityRatio = [...]float64{PriorityCritical: 1, PriorityHigh: 1, PriorityNormal: 0.9, PriorityLow: 0.7}
+
+// adaptiveLimiter 自适应限流算法
+type adaptiveLimiter interface {
+	// Allow 根据当前并发和优先级判断是否放行
+	Allow(inFlight int64, priority int) bool
+	// Observe 记录请求耗时
+	Observe(rt time.Duration)
+}
+
+// shedRoute 路由并发限制，pattern 语法同 routeMatcher
+type shedRoute struct {
+	pattern  string
+	max      int64
+	inFlight int64
+}
+
+// loadShedder 过载保护
+type loadShedder struct {
+	max            int64
+	inFlight       int64
+	routes         []*shedRoute
+	adaptive       adaptiveLimiter
+	priorityHeader string
+}
+
+// newLoadShedder 根据配置构建过载保护
+func newLoadShedder(config *Config) (*loadShedder, error) {
+	s := &loadShedder{
+		max:            int64(config.ShedMaxConcurrency),
+		priorityHeader: config.ShedPriorityHeader,
+	}
+	if s.priorityHeader == "" {
+		s.priorityHeader = HeaderXRequestPriority
+	}
+	for pattern, max := range config.ShedRouteConcurrency {
+		if _, err := newRouteMatcher("ShedRouteConcurrency", []string{pattern}); err != nil {
+			return nil, err
+		}
+		if max <= 0 {
+			return nil, fmt.Errorf("invalid ShedRouteConcurrency %q, concurrency must be greater than 0", pattern)
+		}
+		s.routes = append(s.routes, &shedRoute{pattern: pattern, max: int64(max)})
+	}
+	// 规则越长越具体，优先匹配
+	sort.Slice(s.routes, func(i, j int) bool {
+		if len(s.routes[i].pattern) != len(s.routes[j].pattern) {
+			return len(s.routes[i].pattern) > len(s.routes[j].pattern)
+		}
+		return s.routes[i].pattern < s.routes[j].pattern
+	})
+	switch config.ShedAdaptiveMode {
+	case "":
+	case "cpu":
+		s.adaptive = newBBRLimiter(config.ShedCPUThreshold)
+	case "latency":
+		s.adaptive = newGradientLimiter(config.ShedLatencyTolerance, config.ShedMaxConcurrency)
+	default:
+		return nil, fmt.Errorf("invalid ShedAdaptiveMode %q", config.ShedAdaptiveMode)
+	}
+	return s, nil
+}
+
+// route 返回路由对应的并发限制
+func (s *loadShedder) route(method, routePath string) *shedRoute {
+	for _, route := range s.routes {
+		if matchRoute(route.pattern, method, routePath) {
+			return route
+		}
+	}
+	return nil
+}
+
+// parsePriority 解析请求优先级，支持 critical、high、normal、low 或者 0~3，默认 normal
+func parsePriority(value string) int {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "critical":
+		return PriorityCritical
+	case "high":
+		return PriorityHigh
+	case "low":
+		return PriorityLow
+	case "", "normal":
+		return PriorityNormal
+	}
+	if p, err := strconv.Atoi(value); err == nil && p >= PriorityCritical && p <= PriorityLow {
+		return p
+	}
+	return PriorityNormal
+}
+
+// staticAllow 固定并发限制，低优先级请求提前拒绝
+func staticAllow(inFlight, max int64, priority int) bool {
+	if max <= 0 {
+		return true
+	}
+	if priority == PriorityLow {
+		return float64(inFlight) < float64(max)*lowPriorityStaticRatio
+	}
+	return inFlight < max
+}
+
+// loadSheddingMiddleware 过载保护中间件，超过并发限制或者自适应判断过载时返回503
+func loadSheddingMiddleware(s *loadShedder) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		method := ctx.Req().Method + "." + ctx.SelectedRoutePath()
+		priority := parsePriority(ctx.HeaderParameter(s.priorityHeader))
+		route := s.route(ctx.Req().Method, ctx.SelectedRoutePath())
+
+		// 先占用并发再判断，避免并发请求同时通过检查
+		inFlight := atomic.AddInt64(&s.inFlight, 1) - 1
+		var routeInFlight int64
+		if route != nil {
+			routeInFlight = atomic.AddInt64(&route.inFlight, 1) - 1
+		}
+		release := func() {
+			atomic.AddInt64(&s.inFlight, -1)
+			if route != nil {
+				atomic.AddInt64(&route.inFlight, -1)
+			}
+		}
+		reason := ""
+		switch {
+		case !staticAllow(inFlight, s.max, priority):
+			reason = "concurrency"
+		case route != nil && !staticAllow(routeInFlight, route.max, priority):
+			reason = "route_concurrency"
+		case s.adaptive != nil && !s.adaptive.Allow(inFlight, priority):
+			reason = "adaptive"
+		}
+		if reason != "" {
+			release()
+			serverMetrics.incShed(method, reason)
+			ctx.Response.AddHeader(headerRetryAfter, "1")
+			_ = ctx.WriteErrorString(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+			return
+		}
+
+		beg := time.Now()
+		defer func() {
+			release()
+			if s.adaptive != nil {
+				s.adaptive.Observe(time.Since(beg))
+			}
+		}()
+		ctx.ProcessFilter()
+	})
+}
@@ -0,0 +1,89 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// blockingRoutes 阻塞直到 release 关闭的路由，进入 handler 时写入 entered
+type blockingRoutes struct {
+	entered chan struct{}
+	release chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newBlockingRoutes(s *ereftest.Server) *blockingRoutes {
+	b := &blockingRoutes{entered: make(chan struct{}, 16), release: make(chan struct{})}
+	ws := eref.NewRoute("/api/shed")
+	for _, path := range []string{"/slow", "/other"} {
+		ws.Route(ws.GET(path).To(eref.RouteContext(func(ctx eref.Context) {
+			b.entered <- struct{}{}
+			<-b.release
+		})))
+	}
+	ws.Route(ws.GET("/fast").To(eref.RouteContext(func(ctx eref.Context) {})))
+	s.Add(ws)
+	return b
+}
+
+// hold 并发发起n个阻塞请求，等待全部进入 handler
+func (b *blockingRoutes) hold(s *ereftest.Server, path string, n int) {
+	for i := 0; i < n; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			s.GET(path).Do()
+		}()
+		<-b.entered
+	}
+}
+
+// done 放行阻塞的请求并等待结束
+func (b *blockingRoutes) done() {
+	close(b.release)
+	b.wg.Wait()
+}
+
+func TestLoadSheddingConcurrency(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableLoadShedding": true,
+		"ShedMaxConcurrency": 5,
+	})
+	b := newBlockingRoutes(s)
+	b.hold(s, "/api/shed/slow", 4)
+
+	// 低优先级请求在并发达到上限的80%时拒绝
+	s.GET("/api/shed/fast").Header(eref.HeaderXRequestPriority, "low").Do().
+		ExpectStatus(http.StatusServiceUnavailable).
+		ExpectHeader("Retry-After", "1")
+	s.GET("/api/shed/fast").Do().ExpectStatus(http.StatusOK)
+
+	b.hold(s, "/api/shed/slow", 1)
+	for _, priority := range []string{"", "high", "critical"} {
+		s.GET("/api/shed/fast").Header(eref.HeaderXRequestPriority, priority).Do().
+			ExpectStatus(http.StatusServiceUnavailable)
+	}
+	b.done()
+	// 请求结束后释放并发
+	s.GET("/api/shed/fast").Header(eref.HeaderXRequestPriority, "low").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestLoadSheddingRouteConcurrency(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableLoadShedding":   true,
+		"ShedRouteConcurrency": map[string]int{"GET./api/shed/slow": 1},
+		"ShedPriorityHeader":   "X-Priority",
+	})
+	b := newBlockingRoutes(s)
+	b.hold(s, "/api/shed/slow", 1)
+
+	s.GET("/api/shed/slow").Do().ExpectStatus(http.StatusServiceUnavailable)
+	// 其他路由不受影响
+	b.hold(s, "/api/shed/other", 2)
+	s.GET("/api/shed/fast").Header("X-Priority", "low").Do().ExpectStatus(http.StatusOK)
+	b.done()
+	s.GET("/api/shed/slow").Header("X-Priority", "low").Do().ExpectStatus(http.StatusOK)
+}
@@ -4,14 +4,169 @@ import (
 	"github.com/emicklei/go-restful/v3"
 	"github.com/gotomicro/ego/core/emetric"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
+var (
+	// defaultMetricSizeBuckets 请求响应大小默认桶，64B ~ 1MB
+	defaultMetricSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+	// serverMetrics eref 服务端监控指标，开启 EnableMetricInterceptor 后才会初始化
+	serverMetrics     *httpServerMetrics
+	serverMetricsOnce sync.Once
+)
+
+// httpServerMetrics eref 服务端监控指标
+// emetric.ServerHandleCounter、emetric.ServerHandleHistogram 保持与ego其他组件一致，这里补充HTTP特有的指标
+type httpServerMetrics struct {
+	handleCounter     *emetric.CounterVec
+	handleHistogram   *emetric.HistogramVec
+	requestSizeHisto  *emetric.HistogramVec
+	responseSizeHisto *emetric.HistogramVec
+	inFlightGauge     *emetric.GaugeVec
+	panicCounter      *emetric.CounterVec
+	timeoutCounter    *emetric.CounterVec
+	rateLimitCounter  *emetric.CounterVec
+	shedCounter       *emetric.CounterVec
+}
+
+// initServerMetrics 初始化监控指标，指标全局注册一次，桶配置以第一个构建的组件为准
+func initServerMetrics(config *Config) {
+	serverMetricsOnce.Do(func() {
+		sizeBuckets := config.MetricSizeBuckets
+		if len(sizeBuckets) == 0 {
+			sizeBuckets = defaultMetricSizeBuckets
+		}
+		serverMetrics = &httpServerMetrics{
+			handleCounter: emetric.CounterVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_handle_total",
+				Help:      "Total number of HTTP requests handled by eref server.",
+				Labels:    []string{"method", "peer", "code", "class"},
+			}.Build(),
+			handleHistogram: emetric.HistogramVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_handle_seconds",
+				Help:      "HTTP request latency of eref server.",
+				Labels:    []string{"method", "peer"},
+				Buckets:   config.MetricLatencyBuckets,
+			}.Build(),
+			requestSizeHisto: emetric.HistogramVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_request_size_bytes",
+				Help:      "HTTP request body size of eref server.",
+				Labels:    []string{"method", "peer"},
+				Buckets:   sizeBuckets,
+			}.Build(),
+			responseSizeHisto: emetric.HistogramVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_response_size_bytes",
+				Help:      "HTTP response body size of eref server.",
+				Labels:    []string{"method", "peer"},
+				Buckets:   sizeBuckets,
+			}.Build(),
+			inFlightGauge: emetric.GaugeVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_in_flight_requests",
+				Help:      "Number of HTTP requests currently being handled by eref server.",
+				Labels:    []string{"method"},
+			}.Build(),
+			panicCounter: emetric.CounterVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_panic_total",
+				Help:      "Total number of panics recovered by eref server.",
+				Labels:    []string{"method"},
+			}.Build(),
+			timeoutCounter: emetric.CounterVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_timeout_total",
+				Help:      "Total number of HTTP requests exceeding ContextTimeout.",
+				Labels:    []string{"method"},
+			}.Build(),
+			rateLimitCounter: emetric.CounterVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_rate_limited_total",
+				Help:      "Total number of HTTP requests rejected by rate limit.",
+				Labels:    []string{"method"},
+			}.Build(),
+			shedCounter: emetric.CounterVecOpts{
+				Namespace: emetric.DefaultNamespace,
+				Name:      "http_server_shed_total",
+				Help:      "Total number of HTTP requests rejected by load shedding.",
+				Labels:    []string{"method", "reason"},
+			}.Build(),
+		}
+	})
+}
+
+// incPanic 记录panic次数，未开启监控时忽略
+func (m *httpServerMetrics) incPanic(method string) {
+	if m == nil {
+		return
+	}
+	m.panicCounter.Inc(method)
+}
+
+// incTimeout 记录超时次数，未开启监控时忽略
+func (m *httpServerMetrics) incTimeout(method string) {
+	if m == nil {
+		return
+	}
+	m.timeoutCounter.Inc(method)
+}
+
+// incRateLimited 记录限流次数，未开启监控时忽略
+func (m *httpServerMetrics) incRateLimited(method string) {
+	if m == nil {
+		return
+	}
+	m.rateLimitCounter.Inc(method)
+}
+
+// incShed 记录过载保护拒绝次数，未开启监控时忽略
+func (m *httpServerMetrics) incShed(method, reason string) {
+	if m == nil {
+		return
+	}
+	m.shedCounter.Inc(method, reason)
+}
+
+// statusClass 状态码分类，如 2xx、5xx
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
 func metricServerInterceptor() restful.FilterFunction {
 	return Filter(func(ctx FilterContext) {
 		beg := time.Now()
+		method := ctx.Req().Method + "." + ctx.SelectedRoutePath()
+		peer := extractAPP(ctx.Request)
+		serverMetrics.inFlightGauge.Inc(method)
+		var handled bool
+		defer func() {
+			serverMetrics.inFlightGauge.Add(-1, method)
+			code := ctx.StatusCode()
+			// panic 会在 recoverMiddleware 中写入500，这里先按500记录
+			if !handled {
+				code = http.StatusInternalServerError
+			}
+			cost := time.Since(beg).Seconds()
+			emetric.ServerHandleHistogram.Observe(cost, emetric.TypeHTTP, method, peer)
+			emetric.ServerHandleCounter.Inc(emetric.TypeHTTP, method, peer, strconv.Itoa(code), statusClass(code))
+			serverMetrics.handleHistogram.Observe(cost, method, peer)
+			serverMetrics.handleCounter.Inc(method, peer, strconv.Itoa(code), statusClass(code))
+			var reqSize int64
+			if ctx.Req().ContentLength > 0 {
+				reqSize = ctx.Req().ContentLength
+			}
+			serverMetrics.requestSizeHisto.Observe(float64(reqSize), method, peer)
+			serverMetrics.responseSizeHisto.Observe(float64(ctx.ContentLength()), method, peer)
+		}()
 		ctx.ProcessFilter()
-		emetric.ServerHandleHistogram.Observe(time.Since(beg).Seconds(), emetric.TypeHTTP, ctx.Req().Method+"."+ctx.SelectedRoutePath(), extractAPP(ctx.Request))
-		emetric.ServerHandleCounter.Inc(emetric.TypeHTTP, ctx.Req().Method+"."+ctx.SelectedRoutePath(), extractAPP(ctx.Request), http.StatusText(ctx.StatusCode()), http.StatusText(ctx.StatusCode()))
+		handled = true
 	})
 }
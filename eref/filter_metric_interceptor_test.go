@@ -0,0 +1,130 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// metricValue 指标中标签匹配的计数器、仪表盘的值，直方图返回样本数和总和
+func metricValue(t *testing.T, name string, labels map[string]string) (value, sum float64) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			values := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				values[label.GetName()] = label.GetValue()
+			}
+			for k, v := range labels {
+				if values[k] != v {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				value += metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				value += metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				value += float64(metric.GetHistogram().GetSampleCount())
+				sum += metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return value, sum
+}
+
+// handledCount http_server_handle_total 中路由、状态码对应的请求数
+func handledCount(t *testing.T, method, code string) float64 {
+	t.Helper()
+	value, _ := metricValue(t, "ego_http_server_handle_total", map[string]string{"method": method, "code": code})
+	return value
+}
+
+func TestMetricRecordsRejectedRequests(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":       true,
+		"JWTSecret":       testJWTSecret,
+		"EnableRateLimit": true,
+		"RateLimitRate":   1,
+		"RateLimitWindow": "1m",
+	})
+	ws := eref.NewRoute("/api/metric")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	const method = "GET./api/metric/me"
+	unauthorized, limited := handledCount(t, method, "401"), handledCount(t, method, "429")
+	s.GET("/api/metric/me").Do().ExpectStatus(http.StatusUnauthorized)
+	s.GET("/api/metric/me").Do().ExpectStatus(http.StatusTooManyRequests)
+	// 鉴权、限流拒绝的请求同样记录监控
+	if got := handledCount(t, method, "401") - unauthorized; got != 1 {
+		t.Errorf("401 count = %v, want 1", got)
+	}
+	if got := handledCount(t, method, "429") - limited; got != 1 {
+		t.Errorf("429 count = %v, want 1", got)
+	}
+}
+
+func TestMetricSizesAndInFlight(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableMetricInterceptor": true})
+	const method = "POST./api/metric/echo"
+	inFlight := make(chan float64, 1)
+	ws := eref.NewRoute("/api/metric")
+	ws.Route(ws.POST("/echo").Consumes("text/plain").To(eref.RouteContext(func(ctx eref.Context) {
+		value, _ := metricValue(t, "ego_http_server_in_flight_requests", map[string]string{"method": method})
+		inFlight <- value
+		_, _ = ctx.Write([]byte(strings.Repeat("a", 100)))
+	})))
+	s.Add(ws)
+
+	reqCount, reqSum := metricValue(t, "ego_http_server_request_size_bytes", map[string]string{"method": method})
+	resCount, resSum := metricValue(t, "ego_http_server_response_size_bytes", map[string]string{"method": method})
+	s.POST("/api/metric/echo").Body("text/plain", []byte("0123456789")).Do().ExpectStatus(http.StatusOK)
+
+	if got := <-inFlight; got != 1 {
+		t.Errorf("in-flight during request = %v, want 1", got)
+	}
+	if got, _ := metricValue(t, "ego_http_server_in_flight_requests", map[string]string{"method": method}); got != 0 {
+		t.Errorf("in-flight after request = %v, want 0", got)
+	}
+	if count, sum := metricValue(t, "ego_http_server_request_size_bytes", map[string]string{"method": method}); count-reqCount != 1 || sum-reqSum != 10 {
+		t.Errorf("request size count = %v, sum = %v, want 1, 10", count-reqCount, sum-reqSum)
+	}
+	if count, sum := metricValue(t, "ego_http_server_response_size_bytes", map[string]string{"method": method}); count-resCount != 1 || sum-resSum != 100 {
+		t.Errorf("response size count = %v, sum = %v, want 1, 100", count-resCount, sum-resSum)
+	}
+	if got, _ := metricValue(t, "ego_http_server_handle_total", map[string]string{"method": method, "code": "200", "class": "2xx"}); got == 0 {
+		t.Error("handle total not recorded with status class")
+	}
+}
+
+func TestMetricPanic(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableMetricInterceptor": true})
+	const method = "GET./api/metric/panic"
+	ws := eref.NewRoute("/api/metric")
+	ws.Route(ws.GET("/panic").To(eref.RouteContext(func(ctx eref.Context) {
+		panic("boom")
+	})))
+	s.Add(ws)
+
+	panics, _ := metricValue(t, "ego_http_server_panic_total", map[string]string{"method": method})
+	errors := handledCount(t, method, "500")
+	s.GET("/api/metric/panic").Do().ExpectStatus(http.StatusInternalServerError)
+	if got, _ := metricValue(t, "ego_http_server_panic_total", map[string]string{"method": method}); got-panics != 1 {
+		t.Errorf("panic count = %v, want 1", got-panics)
+	}
+	if got := handledCount(t, method, "500") - errors; got != 1 {
+		t.Errorf("500 count = %v, want 1", got)
+	}
+}
@@ -0,0 +1,298 @@
+package eref
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// HeaderXForwardedClientCert envoy、istio 等代理转发客户端证书信息的header
+const HeaderXForwardedClientCert = "X-Forwarded-Client-Cert"
+
+// clientCertificateAttribute 客户端证书在 restful.Request 中的属性名
+const clientCertificateAttribute = "client_certificate"
+
+// ClientCertificate 客户端证书身份
+type ClientCertificate struct {
+	Subject    string   // 证书主题，RFC 2253 格式
+	CommonName string   // 证书CN
+	DNSNames   []string // DNS SAN
+	URIs       []string // URI SAN
+	SPIFFEID   string   // spiffe:// 开头的 URI SAN
+	Hash       string   // 证书DER编码的sha256
+	Forwarded  bool     // 是否来自可信代理转发的header
+}
+
+// identity 证书对应的调用方标识，优先使用 SPIFFE ID
+func (c ClientCertificate) identity() string {
+	if c.SPIFFEID != "" {
+		return c.SPIFFEID
+	}
+	return c.CommonName
+}
+
+// names 参与白名单匹配的身份
+func (c ClientCertificate) names() []string {
+	names := make([]string, 0, len(c.DNSNames)+len(c.URIs)+1)
+	if c.CommonName != "" {
+		names = append(names, c.CommonName)
+	}
+	names = append(names, c.DNSNames...)
+	return append(names, c.URIs...)
+}
+
+// ClientCertificate 双向TLS鉴权通过的客户端证书，未开启 EnableMTLS 或者没有证书时返回false
+func (c Context) ClientCertificate() (ClientCertificate, bool) {
+	cert, ok := c.Request.Attribute(clientCertificateAttribute).(ClientCertificate)
+	return cert, ok
+}
+
+// newClientCertificate 从已校验的证书中提取身份
+func newClientCertificate(cert *x509.Certificate) ClientCertificate {
+	hash := sha256.Sum256(cert.Raw)
+	c := ClientCertificate{
+		Subject:    cert.Subject.String(),
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		Hash:       hex.EncodeToString(hash[:]),
+	}
+	for _, uri := range cert.URIs {
+		c.URIs = append(c.URIs, uri.String())
+		if spiffeID(uri.String()) && c.SPIFFEID == "" {
+			c.SPIFFEID = uri.String()
+		}
+	}
+	return c
+}
+
+// parseForwardedClientCert 解析 X-Forwarded-Client-Cert，格式为 envoy 定义的 Key=Value;Key=Value，多个代理之间用逗号分隔
+// 只取最后一段，即离服务最近的可信代理校验过的客户端证书
+func parseForwardedClientCert(header string) (ClientCertificate, error) {
+	elements := splitQuoted(header, ',')
+	if len(elements) == 0 {
+		return ClientCertificate{}, errors.New("empty forwarded client cert")
+	}
+	c := ClientCertificate{Forwarded: true}
+	for _, pair := range splitQuoted(elements[len(elements)-1], ';') {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return ClientCertificate{}, fmt.Errorf("invalid forwarded client cert pair %q", pair)
+		}
+		value := unquote(kv[1])
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "hash":
+			c.Hash = value
+		case "subject":
+			c.Subject = value
+			c.CommonName = subjectCommonName(value)
+		case "uri":
+			c.URIs = append(c.URIs, value)
+			if spiffeID(value) && c.SPIFFEID == "" {
+				c.SPIFFEID = value
+			}
+		case "dns":
+			c.DNSNames = append(c.DNSNames, value)
+		}
+	}
+	return c, nil
+}
+
+// splitQuoted 按分隔符切分，忽略双引号内的分隔符
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts   []string
+		quoted  bool
+		escaped bool
+		start   int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			if part := strings.TrimSpace(s[start:i]); part != "" {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if part := strings.TrimSpace(s[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// subjectCommonName 从 RFC 2253 格式的主题中提取CN
+func subjectCommonName(subject string) string {
+	for _, rdn := range splitQuoted(subject, ',') {
+		if kv := strings.SplitN(rdn, "=", 2); len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "CN") {
+			return unquote(kv[1])
+		}
+	}
+	return ""
+}
+
+// mtlsRoute 路由证书白名单，pattern 语法同 routeMatcher
+type mtlsRoute struct {
+	pattern    string
+	identities []string
+}
+
+// mtlsAuth 双向TLS鉴权
+type mtlsAuth struct {
+	forwardedHeader string
+	trustedProxies  ipNets
+	identities      []string
+	routes          []mtlsRoute
+	excludes        routeMatcher
+}
+
+// newMTLSAuth 根据配置构建双向TLS鉴权
+func newMTLSAuth(config *Config) (*mtlsAuth, error) {
+	excludes, err := newRouteMatcher("MTLSExcludeRoutes", config.MTLSExcludeRoutes)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := parseIPNets("MTLSTrustedProxies", config.MTLSTrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if config.MTLSForwardedHeader != "" && len(trustedProxies) == 0 {
+		return nil, errors.New("invalid mtls config, MTLSTrustedProxies is required when MTLSForwardedHeader is set")
+	}
+	if err := validIdentityPatterns("MTLSAllowedIdentities", config.MTLSAllowedIdentities); err != nil {
+		return nil, err
+	}
+	a := &mtlsAuth{
+		forwardedHeader: config.MTLSForwardedHeader,
+		trustedProxies:  trustedProxies,
+		identities:      config.MTLSAllowedIdentities,
+		excludes:        excludes,
+	}
+	for pattern, identities := range config.MTLSRouteAllowedIdentities {
+		if _, err := newRouteMatcher("MTLSRouteAllowedIdentities", []string{pattern}); err != nil {
+			return nil, err
+		}
+		if err := validIdentityPatterns(fmt.Sprintf("MTLSRouteAllowedIdentities %q", pattern), identities); err != nil {
+			return nil, err
+		}
+		a.routes = append(a.routes, mtlsRoute{pattern: pattern, identities: identities})
+	}
+	// 规则越长越具体，优先匹配
+	sort.Slice(a.routes, func(i, j int) bool {
+		if len(a.routes[i].pattern) != len(a.routes[j].pattern) {
+			return len(a.routes[i].pattern) > len(a.routes[j].pattern)
+		}
+		return a.routes[i].pattern < a.routes[j].pattern
+	})
+	return a, nil
+}
+
+func validIdentityPatterns(name string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s %q, %w", name, pattern, err)
+		}
+	}
+	return nil
+}
+
+// allowed 返回路由的证书白名单，为空表示不限制
+func (a *mtlsAuth) allowed(method, routePath string) []string {
+	for _, route := range a.routes {
+		if matchRoute(route.pattern, method, routePath) {
+			return route.identities
+		}
+	}
+	return a.identities
+}
+
+// certificate 优先使用TLS握手校验过的证书，其次是可信代理转发的证书
+func (a *mtlsAuth) certificate(ctx Context) (ClientCertificate, bool, error) {
+	if state := ctx.Req().TLS; state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		return newClientCertificate(state.VerifiedChains[0][0]), true, nil
+	}
+	if a.forwardedHeader == "" {
+		return ClientCertificate{}, false, nil
+	}
+	header := ctx.HeaderParameter(a.forwardedHeader)
+	if header == "" || !a.trustedProxies.Contains(ctx.GetPeerIP()) {
+		return ClientCertificate{}, false, nil
+	}
+	cert, err := parseForwardedClientCert(header)
+	if err != nil {
+		return ClientCertificate{}, false, err
+	}
+	return cert, true, nil
+}
+
+// matchIdentity 证书的 SPIFFE ID、URI SAN、DNS SAN、CN 任意一个命中白名单即可
+func matchIdentity(cert ClientCertificate, patterns []string) bool {
+	for _, pattern := range patterns {
+		for _, name := range cert.names() {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mtlsMiddleware 双向TLS鉴权中间件，提取客户端证书身份，路由配置了白名单时没有证书返回401，不在白名单返回403
+func mtlsMiddleware(a *mtlsAuth) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		if skipAuth(ctx.Context, a.excludes) {
+			ctx.ProcessFilter()
+			return
+		}
+		cert, ok, err := a.certificate(ctx.Context)
+		if err != nil {
+			ctx.Log.Debug("mtls auth fail", elog.FieldErr(err))
+		}
+		allowed := a.allowed(ctx.Req().Method, ctx.SelectedRoutePath())
+		if !ok {
+			if len(allowed) > 0 {
+				_ = ctx.WriteErrorString(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
+			ctx.ProcessFilter()
+			return
+		}
+		if len(allowed) > 0 && !matchIdentity(cert, allowed) {
+			ctx.Log.Debug("mtls auth deny", elog.String("identity", cert.identity()))
+			_ = ctx.WriteErrorString(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		ctx.SetAttribute(clientCertificateAttribute, cert)
+		ctx.setIdentity(Identity{Type: IdentityMTLS, Subject: cert.identity()})
+		ctx.AddLogFields(elog.String("peer_identity", cert.identity()))
+		ctx.ProcessFilter()
+	})
+}
+
+// spiffeID 校验 SPIFFE ID 格式
+func spiffeID(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme == "spiffe" && u.Host != ""
+}
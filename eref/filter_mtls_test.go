@@ -0,0 +1,85 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"net/http"
+	"testing"
+)
+
+// forwardedClientCert envoy 格式的客户端证书header
+func forwardedClientCert(spiffeID string) string {
+	return `Hash=abc;Subject="CN=client,O=eref";URI=` + spiffeID + `;DNS=client.local`
+}
+
+func TestMTLSForwardedClientCert(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableMTLS":            true,
+		"MTLSForwardedHeader":   eref.HeaderXForwardedClientCert,
+		"MTLSTrustedProxies":    []string{"10.0.0.0/8"},
+		"MTLSAllowedIdentities": []string{"spiffe://cluster.local/ns/*/sa/billing"},
+		"MTLSRouteAllowedIdentities": map[string][]string{
+			"/api/admin": {"spiffe://cluster.local/ns/ops/sa/admin"},
+		},
+		"MTLSExcludeRoutes": []string{"/api/health"},
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	identityRoute(ws, "/admin")
+	identityRoute(ws, "/health")
+	ws.Route(ws.GET("/cert").To(eref.RouteContext(func(ctx eref.Context) {
+		cert, _ := ctx.ClientCertificate()
+		_ = ctx.WriteEntity(cert)
+	})))
+	s.Add(ws)
+
+	const (
+		proxy   = "10.0.0.1:1234"
+		billing = "spiffe://cluster.local/ns/pay/sa/billing"
+		admin   = "spiffe://cluster.local/ns/ops/sa/admin"
+	)
+	var identity eref.Identity
+	s.GET("/api/me").RemoteAddr(proxy).Header(eref.HeaderXForwardedClientCert, forwardedClientCert(billing)).Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&identity)
+	if identity.Type != eref.IdentityMTLS || identity.Subject != billing {
+		t.Errorf("identity = %+v", identity)
+	}
+	var cert eref.ClientCertificate
+	s.GET("/api/cert").RemoteAddr(proxy).Header(eref.HeaderXForwardedClientCert, forwardedClientCert(billing)).Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&cert)
+	if cert.CommonName != "client" || cert.SPIFFEID != billing || !cert.Forwarded || len(cert.DNSNames) != 1 {
+		t.Errorf("client certificate = %+v", cert)
+	}
+
+	// 不是可信代理转发的header不生效
+	s.GET("/api/me").RemoteAddr("192.0.2.1:1234").Header(eref.HeaderXForwardedClientCert, forwardedClientCert(billing)).Do().
+		ExpectStatus(http.StatusUnauthorized)
+	// 不在全局白名单中
+	s.GET("/api/me").RemoteAddr(proxy).Header(eref.HeaderXForwardedClientCert, forwardedClientCert("spiffe://cluster.local/ns/pay/sa/other")).Do().
+		ExpectStatus(http.StatusForbidden)
+	// 路由白名单优先于全局白名单
+	s.GET("/api/admin").RemoteAddr(proxy).Header(eref.HeaderXForwardedClientCert, forwardedClientCert(billing)).Do().
+		ExpectStatus(http.StatusForbidden)
+	s.GET("/api/admin").RemoteAddr(proxy).Header(eref.HeaderXForwardedClientCert, forwardedClientCert(admin)).Do().
+		ExpectStatus(http.StatusOK)
+	s.GET("/api/health").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestMTLSOptionalWithoutAllowList(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableMTLS":          true,
+		"MTLSForwardedHeader": eref.HeaderXForwardedClientCert,
+		"MTLSTrustedProxies":  []string{"10.0.0.0/8"},
+	})
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+
+	// 没有白名单时不要求证书，也没有身份
+	var identity eref.Identity
+	s.GET("/api/me").Do().ExpectStatus(http.StatusOK).DecodeJSON(&identity)
+	if identity.Type != "" {
+		t.Errorf("identity = %+v, want empty", identity)
+	}
+}
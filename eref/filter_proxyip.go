@@ -32,11 +32,21 @@ var (
 )
 
 func filterProxyIp(logger *elog.Component, config *Config) restful.FilterFunction {
+	proxies, err := newProxyTrust(config)
+	if err != nil {
+		logger.Panic("build proxy ip filter error", elog.FieldErr(err))
+	}
 	return Filter(func(ctx FilterContext) {
+		if !proxies.trusted(ctx.GetPeerIP()) {
+			ctx.SetAttribute("ip", ctx.GetPeerIP())
+			ctx.ProcessFilter()
+			return
+		}
 		// Set the remote IP with the value passed from the proxy.
-		ip := getIP(ctx.Req())
+		ip := proxies.forwardedIP(ctx.Req())
 		// IP 写入上下文
 		ctx.SetAttribute("ip", ip)
+		ctx.resetLogger()
 		// Set the scheme (proto) with the value passed from the proxy.
 		if scheme := getScheme(ctx.Req()); scheme != "" {
 			ctx.Req().URL.Scheme = scheme
@@ -49,44 +59,98 @@ func filterProxyIp(logger *elog.Component, config *Config) restful.FilterFunctio
 	})
 }
 
-// getIP retrieves the IP from the X-Forwarded-For, X-Real-IP and RFC7239
-// Forwarded headers (in that order).
-func getIP(r *http.Request) string {
-	var fwd string
-	var err error
+// proxyTrust 可信代理，只有来自可信代理的请求才读取转发的header，避免伪造客户端IP
+type proxyTrust struct {
+	all  bool // 未配置 TrustedProxies 时信任全部，兼容旧配置；开启了依赖客户端IP的访问控制、限流时不信任任何代理
+	nets ipNets
+}
+
+// newProxyTrust 根据 TrustedProxies 构建可信代理
+func newProxyTrust(config *Config) (*proxyTrust, error) {
+	nets, err := parseIPNets("TrustedProxies", config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyTrust{
+		all:  len(nets) == 0 && !clientIPEnforced(config),
+		nets: nets,
+	}, nil
+}
+
+// clientIPEnforced 是否开启了依赖客户端IP的访问控制或者限流，客户端可以伪造转发的header绕过
+func clientIPEnforced(config *Config) bool {
+	if config.EnableIPFilter || len(config.DocsUIAllowIPs) > 0 {
+		return true
+	}
+	return config.EnableRateLimit && config.rateLimitKeyFunc == nil && (config.RateLimitKey == "" || config.RateLimitKey == "ip")
+}
+
+// trusted ip 是否为可信代理
+func (p *proxyTrust) trusted(ip string) bool {
+	return p.all || p.nets.Contains(ip)
+}
+
+// clientIP 不经过 filter 的 http.Handler 中获取客户端IP，规则同 filterProxyIp
+func (p *proxyTrust) clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !p.trusted(peer) {
+		return peer
+	}
+	return p.forwardedIP(r)
+}
 
-	addr := strings.TrimSpace(r.RemoteAddr)
-	if fwd = r.Header.Get(xForwardedFor); fwd != "" {
-		// Only grab the first (client) address. Note that '192.168.0.1,
-		// 10.1.1.1' is a valid key for X-Forwarded-For where addresses after
-		// the first may represent forwarding proxies earlier in the chain.
-		s := strings.Index(fwd, ", ")
-		if s == -1 {
-			s = len(fwd)
+// forwardedIP 从右往左跳过可信代理，返回第一个不可信的地址，左边的地址可能由客户端伪造
+// 全部为可信代理时返回最左边的地址，遇到不合法的地址时返回空
+func (p *proxyTrust) forwardedIP(r *http.Request) string {
+	hops := forwardedHops(r)
+	if len(hops) == 0 {
+		return normalizeIP(r.RemoteAddr)
+	}
+	for i := len(hops) - 1; i > 0; i-- {
+		if ip := normalizeIP(hops[i]); ip == "" || !p.trusted(ip) {
+			return ip
 		}
-		addr = fwd[:s]
-	} else if fwd = r.Header.Get(xRealIP); fwd != "" {
+	}
+	return normalizeIP(hops[0])
+}
+
+// forwardedHops retrieves the forwarding chain from the X-Forwarded-For,
+// X-Real-IP and RFC7239 Forwarded headers (in that order).
+func forwardedHops(r *http.Request) []string {
+	if fwd := r.Header.Values(xForwardedFor); len(fwd) > 0 {
+		// 多个 X-Forwarded-For header 按顺序合并
+		return strings.Split(strings.Join(fwd, ","), ",")
+	}
+	if fwd := r.Header.Get(xRealIP); fwd != "" {
 		// X-Real-IP should only contain one IP address (the client making the
 		// request).
-		addr = fwd
-	} else if fwd = r.Header.Get(forwarded); fwd != "" {
-		// match should contain at least two elements if the protocol was
-		// specified in the Forwarded header. The first element will always be
-		// the 'for=' capture, which we ignore. In the case of multiple IP
-		// addresses (for=8.8.8.8, 8.8.4.4,172.16.1.20 is valid) we only
-		// extract the first, which should be the client IP.
-		if match := forRegex.FindStringSubmatch(fwd); len(match) > 1 {
-			// IPv6 addresses in Forwarded headers are quoted-strings. We strip
-			// these quotes.
-			addr = strings.Trim(match[1], `"`)
+		return []string{fwd}
+	}
+	var hops []string
+	for _, fwd := range r.Header.Values(forwarded) {
+		// In the case of multiple IP addresses (for=8.8.8.8, for=8.8.4.4) every
+		// element is a hop. IPv6 addresses in Forwarded headers are
+		// quoted-strings. We strip these quotes.
+		for _, match := range forRegex.FindAllStringSubmatch(fwd, -1) {
+			hops = append(hops, strings.Trim(match[1], `"`))
 		}
 	}
+	return hops
+}
 
-	addr, _, err = net.SplitHostPort(addr)
-	if err != nil {
-		return ""
+// normalizeIP 转发的header中通常只有IP，没有端口
+func normalizeIP(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if ip := net.ParseIP(strings.Trim(addr, "[]")); ip != nil {
+		return ip.String()
 	}
-	return addr
+	return ""
 }
 
 // getScheme retrieves the scheme from the X-Forwarded-Proto and RFC7239
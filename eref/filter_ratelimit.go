@@ -0,0 +1,181 @@
+package eref
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+)
+
+// RateLimitKeyFunc 自定义限流维度，返回空字符串时不限流
+type RateLimitKeyFunc func(ctx Context) string
+
+// rateLimitRoute 路由限流规则，pattern 语法同 routeMatcher
+type rateLimitRoute struct {
+	pattern string
+	limit   RateLimit
+}
+
+// rateLimiter 限流器
+type rateLimiter struct {
+	store   RateLimitStore
+	keyFunc RateLimitKeyFunc
+	global  *RateLimit
+	routes  []rateLimitRoute
+}
+
+// newRateLimiter 根据配置构建限流器
+func newRateLimiter(config *Config) (*rateLimiter, error) {
+	l := &rateLimiter{
+		store:   config.rateLimitStore,
+		keyFunc: config.rateLimitKeyFunc,
+	}
+	if l.store == nil {
+		l.store = NewMemoryRateLimitStore()
+	}
+	if l.keyFunc == nil {
+		keyFunc, err := rateLimitKeyFuncByName(config.RateLimitKey)
+		if err != nil {
+			return nil, err
+		}
+		l.keyFunc = keyFunc
+	}
+	base := RateLimit{
+		Algorithm: config.RateLimitAlgorithm,
+		Rate:      config.RateLimitRate,
+		Window:    config.RateLimitWindow,
+		Burst:     config.RateLimitBurst,
+	}
+	if base.Rate > 0 {
+		global, err := normalizeRateLimit(base, base, "RateLimitRate")
+		if err != nil {
+			return nil, err
+		}
+		l.global = &global
+	}
+	for pattern, limit := range config.RateLimitRoutes {
+		if _, err := newRouteMatcher("RateLimitRoutes", []string{pattern}); err != nil {
+			return nil, err
+		}
+		limit, err := normalizeRateLimit(limit, base, fmt.Sprintf("RateLimitRoutes %q", pattern))
+		if err != nil {
+			return nil, err
+		}
+		l.routes = append(l.routes, rateLimitRoute{pattern: pattern, limit: limit})
+	}
+	// 规则越长越具体，优先匹配
+	sort.Slice(l.routes, func(i, j int) bool {
+		if len(l.routes[i].pattern) != len(l.routes[j].pattern) {
+			return len(l.routes[i].pattern) > len(l.routes[j].pattern)
+		}
+		return l.routes[i].pattern < l.routes[j].pattern
+	})
+	return l, nil
+}
+
+// normalizeRateLimit 补全限流规则，未配置的字段使用全局配置
+func normalizeRateLimit(limit RateLimit, base RateLimit, name string) (RateLimit, error) {
+	if limit.Algorithm == "" {
+		limit.Algorithm = base.Algorithm
+	}
+	if limit.Algorithm == "" {
+		limit.Algorithm = RateLimitTokenBucket
+	}
+	if limit.Window <= 0 {
+		limit.Window = base.Window
+	}
+	if limit.Window <= 0 {
+		limit.Window = time.Second
+	}
+	switch limit.Algorithm {
+	case RateLimitTokenBucket, RateLimitSlidingWindow:
+	default:
+		return limit, fmt.Errorf("invalid %s, unknown algorithm %q", name, limit.Algorithm)
+	}
+	if limit.Rate <= 0 {
+		return limit, fmt.Errorf("invalid %s, rate must be greater than 0", name)
+	}
+	return limit, nil
+}
+
+// rateLimitKeyFuncByName 内置的限流维度
+func rateLimitKeyFuncByName(name string) (RateLimitKeyFunc, error) {
+	switch name {
+	case "", "ip":
+		return func(ctx Context) string {
+			return ctx.ClientIP()
+		}, nil
+	case "app":
+		return func(ctx Context) string {
+			return extractAPP(ctx.Request)
+		}, nil
+	case "route":
+		return func(ctx Context) string {
+			return ctx.Req().Method + "." + ctx.SelectedRoutePath()
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid RateLimitKey %q", name)
+	}
+}
+
+// rule 返回路由对应的限流规则，以及规则的作用域
+func (l *rateLimiter) rule(method, routePath string) (RateLimit, string, bool) {
+	for _, route := range l.routes {
+		if matchRoute(route.pattern, method, routePath) {
+			return route.limit, route.pattern, true
+		}
+	}
+	if l.global != nil {
+		return *l.global, "*", true
+	}
+	return RateLimit{}, "", false
+}
+
+// rateLimitMiddleware 限流中间件，超过限制返回429
+func rateLimitMiddleware(l *rateLimiter) restful.FilterFunction {
+	return Filter(func(ctx FilterContext) {
+		limit, scope, ok := l.rule(ctx.Req().Method, ctx.SelectedRoutePath())
+		if !ok {
+			ctx.ProcessFilter()
+			return
+		}
+		key := l.keyFunc(ctx.Context)
+		if key == "" {
+			ctx.ProcessFilter()
+			return
+		}
+		res, err := l.store.Allow(ctx.Context.Context(), scope+"|"+key, limit)
+		if err != nil {
+			// 存储异常时放行，避免限流组件故障影响业务
+			ctx.Log.Warn("rate limit store error", elog.FieldErr(err), elog.FieldKey(key))
+			ctx.ProcessFilter()
+			return
+		}
+		ctx.Response.AddHeader(headerRateLimitLimit, strconv.Itoa(res.Limit))
+		ctx.Response.AddHeader(headerRateLimitRemaining, strconv.Itoa(res.Remaining))
+		ctx.Response.AddHeader(headerRateLimitReset, ceilSeconds(res.Reset))
+		if !res.Allowed {
+			serverMetrics.incRateLimited(ctx.Req().Method + "." + ctx.SelectedRoutePath())
+			ctx.Response.AddHeader(headerRetryAfter, ceilSeconds(res.RetryAfter))
+			_ = ctx.WriteErrorString(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+			return
+		}
+		ctx.ProcessFilter()
+	})
+}
+
+// ceilSeconds 向上取整的秒数
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,110 @@
+package eref_test
+
+import (
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"net/http"
+	"testing"
+)
+
+// pingRoutes 返回 pong 的路由
+func pingRoutes(s *ereftest.Server, paths ...string) {
+	ws := eref.NewRoute("/api")
+	for _, path := range paths {
+		ws.Route(ws.GET(path).To(eref.RouteContext(func(ctx eref.Context) {
+			_, _ = ctx.Write([]byte("pong"))
+		})))
+	}
+	s.Add(ws)
+}
+
+func TestRateLimit(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRateLimit": true,
+		"RateLimitRate":   2,
+		"RateLimitWindow": "1m",
+	})
+	pingRoutes(s, "/ping")
+
+	const client = "192.0.2.1:1234"
+	s.GET("/api/ping").RemoteAddr(client).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("RateLimit-Limit", "2").
+		ExpectHeader("RateLimit-Remaining", "1")
+	s.GET("/api/ping").RemoteAddr(client).Do().ExpectStatus(http.StatusOK)
+	res := s.GET("/api/ping").RemoteAddr(client).Do().
+		ExpectStatus(http.StatusTooManyRequests).
+		ExpectHeader("RateLimit-Remaining", "0")
+	if res.Header("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+	// 按客户端IP分别限流
+	s.GET("/api/ping").RemoteAddr("192.0.2.2:1234").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRateLimit": true,
+		"RateLimitWindow": "1m",
+		"RateLimitRoutes": map[string]interface{}{
+			"GET./api/login": map[string]interface{}{"Rate": 1, "Algorithm": eref.RateLimitSlidingWindow},
+		},
+	})
+	pingRoutes(s, "/login", "/ping")
+
+	s.GET("/api/login").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/login").Do().ExpectStatus(http.StatusTooManyRequests)
+	// 没有匹配规则、也没有全局限流时不限流
+	for i := 0; i < 3; i++ {
+		s.GET("/api/ping").Do().ExpectStatus(http.StatusOK)
+	}
+}
+
+func TestRateLimitIgnoresUntrustedForwardedFor(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRateLimit": true,
+		"RateLimitRate":   1,
+		"RateLimitWindow": "1m",
+	})
+	pingRoutes(s, "/ping")
+
+	// 没有配置可信代理时，伪造 X-Forwarded-For 不能绕过限流
+	s.GET("/api/ping").RemoteAddr("198.51.100.1:1234").Header("X-Forwarded-For", "192.0.2.1").Do().
+		ExpectStatus(http.StatusOK)
+	s.GET("/api/ping").RemoteAddr("198.51.100.1:1234").Header("X-Forwarded-For", "192.0.2.2").Do().
+		ExpectStatus(http.StatusTooManyRequests)
+}
+
+func TestRateLimitTrustedProxies(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRateLimit": true,
+		"RateLimitRate":   1,
+		"RateLimitWindow": "1m",
+		"TrustedProxies":  []string{"10.0.0.0/8"},
+	})
+	pingRoutes(s, "/ping")
+
+	const proxy = "10.0.0.1:1234"
+	s.GET("/api/ping").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.1").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/ping").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.2").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/ping").RemoteAddr(proxy).Header("X-Forwarded-For", "192.0.2.1").Do().
+		ExpectStatus(http.StatusTooManyRequests)
+}
+
+func TestRateLimitKeyFunc(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRateLimit": true,
+		"RateLimitRate":   1,
+		"RateLimitWindow": "1m",
+	}, eref.WithRateLimitKeyFunc(func(ctx eref.Context) string {
+		return ctx.HeaderParameter("X-Tenant")
+	}))
+	pingRoutes(s, "/ping")
+
+	s.GET("/api/ping").Header("X-Tenant", "a").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/ping").Header("X-Tenant", "a").Do().ExpectStatus(http.StatusTooManyRequests)
+	s.GET("/api/ping").Header("X-Tenant", "b").Do().ExpectStatus(http.StatusOK)
+	// 返回空字符串时不限流
+	s.GET("/api/ping").Do().ExpectStatus(http.StatusOK)
+	s.GET("/api/ping").Do().ExpectStatus(http.StatusOK)
+}
@@ -1,14 +1,16 @@
 package eref
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/emicklei/go-restful/v3"
+	"github.com/google/cel-go/common/types"
 	"github.com/gotomicro/ego/core/elog"
-	"github.com/gotomicro/ego/core/etrace"
-	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
+	rpcpb "google.golang.org/genproto/googleapis/rpc/context/attribute_context"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"io"
 	"io/ioutil"
 	"net"
@@ -27,19 +29,45 @@ var (
 )
 
 type resWriter struct {
-	restful.EntityReaderWriter
+	http.ResponseWriter
 	body *bytes.Buffer
 }
 
-func (w *resWriter) Write(resp *restful.Response, status int, v interface{}) error {
-	b, err := json.Marshal(v)
-	if err != nil {
-		return err
+func (w *resWriter) Write(b []byte) (int, error) {
+	// 流式响应不记录body，避免长连接占用内存
+	if !isEventStream(w.Header()) {
+		w.body.Write(b)
 	}
-	if _, err = w.body.Write(b); err != nil {
-		return err
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *resWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements http.CloseNotifier
+func (w *resWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	// 不支持时返回永远不会关闭的 channel
+	return make(chan bool)
+}
+
+// Hijack implements http.Hijacker，websocket 需要劫持连接
+func (w *resWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
 	}
-	return w.EntityReaderWriter.Write(resp, status, v)
+	return nil, nil, errors.New("response writer does not implement http.Hijacker")
+}
+
+// Unwrap 用于 http.ResponseController
+func (w *resWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 // extractAPP 提取header头中的app信息
@@ -52,21 +80,19 @@ func recoverMiddleware(logger *elog.Component, config *Config) restful.FilterFun
 	return Filter(func(ctx FilterContext) {
 		var rb bytes.Buffer
 		var rw *resWriter
-		var ok bool
-		var entity restful.EntityReaderWriter
 
 		config.mu.RLock()
 		// 保存body
 		ctx.Req().Body = ioutil.NopCloser(io.TeeReader(ctx.Req().Body, &rb))
 		ctx.SetAttribute("body", rb.Bytes())
 
+		// 只有开启了EnableAccessInterceptorRes时才替换response writer
 		if config.EnableAccessInterceptorRes {
-			if entity, ok = ctx.Response.EntityWriter(); ok {
-				rw = &resWriter{
-					EntityReaderWriter: entity,
-					body:               new(bytes.Buffer),
-				}
+			rw = &resWriter{
+				ResponseWriter: ctx.Response.ResponseWriter,
+				body:           new(bytes.Buffer),
 			}
+			ctx.Response.ResponseWriter = rw
 		}
 		config.mu.RUnlock()
 
@@ -82,34 +108,47 @@ func recoverMiddleware(logger *elog.Component, config *Config) restful.FilterFun
 				elog.FieldType("http"), // GET, POST
 				elog.FieldCost(cost),
 				elog.FieldMethod(ctx.Req().Method+"."+ctx.Request.SelectedRoutePath()), // 完整路径
-				elog.FieldAddr(ctx.Req().URL.RequestURI()),
+				elog.FieldAddr(config.redactor.URI(ctx.Req().URL)),
 				elog.FieldIP(ctx.ClientIP()),
 				elog.FieldSize(int32(ctx.Response.ContentLength())),
 				elog.FieldPeerIP(ctx.GetPeerIP()),
 			)
+			if id := ctx.RequestID(); id != "" {
+				fields = append(fields, fieldRequestID(id))
+			}
+			fields = append(fields, ctx.LogFields()...)
 			// 是否开启链路追踪，默认开启
-			if config.EnableTraceInterceptor && opentracing.IsGlobalTracerRegistered() {
-				fields = append(fields, elog.FieldTid(etrace.ExtractTraceID(ctx.Context.Context())))
+			if config.EnableTraceInterceptor {
+				if tid := extractTraceID(ctx.Context.Context()); tid != "" {
+					fields = append(fields, elog.FieldTid(tid))
+				}
 			}
 
 			config.mu.RLock()
-			if config.EnableAccessInterceptorReq {
-				fields = append(fields, elog.Any("req", map[string]interface{}{
-					"metadata": ctx.Req().Header,
-					"payload":  rb.String(),
-				}))
-			}
+			if config.EnableAccessInterceptorReq || config.EnableAccessInterceptorRes {
+				out := checkFilter(logger, config, ctx.Context)
+				if config.EnableAccessInterceptorReq && out {
+					fields = append(fields, elog.Any("req", map[string]interface{}{
+						"metadata": config.redactor.Header(ctx.Req().Header),
+						"payload":  config.redactor.Payload(rb.Bytes()),
+					}))
+				}
 
-			if config.EnableAccessInterceptorRes && ok {
-				fields = append(fields, elog.Any("res", map[string]interface{}{
-					"metadata": ctx.Header(),
-					"payload":  rw.body.String(),
-				}))
+				if config.EnableAccessInterceptorRes && rw != nil && out {
+					fields = append(fields, elog.Any("res", map[string]interface{}{
+						"metadata": config.redactor.Header(ctx.Header()),
+						"payload":  config.redactor.Payload(rw.body.Bytes()),
+					}))
+				}
 			}
+			enableAccessInterceptor := config.EnableAccessInterceptor
+			slowLogThreshold := config.SlowLogThreshold
+			sampleRate := config.AccessLogSampleRate
 			config.mu.RUnlock()
 
-			// slow log
-			if config.SlowLogThreshold > time.Duration(0) && config.SlowLogThreshold < cost {
+			// slow log，流式响应的耗时是连接时长，不记录慢日志
+			slow := slowLogThreshold > time.Duration(0) && slowLogThreshold < cost && !isEventStream(ctx.Header())
+			if slow {
 				logger.Warn("slow", fields...)
 			}
 
@@ -125,12 +164,14 @@ func recoverMiddleware(logger *elog.Component, config *Config) restful.FilterFun
 				if brokenPipe {
 					// If the connection is dead, we can't write a status to it.
 					_ = ctx.WriteError(http.StatusInternalServerError, rec.(error)) // nolint: errcheck
-				} else {
+				} else if !isEventStream(ctx.Header()) {
+					// SSE 已经发送了响应头
 					ctx.WriteHeader(http.StatusInternalServerError)
 				}
 
 				event = "recover"
 				stackInfo := stack(3)
+				serverMetrics.incPanic(ctx.Req().Method + "." + ctx.SelectedRoutePath())
 
 				fields = append(fields,
 					elog.FieldEvent(event),
@@ -142,19 +183,75 @@ func recoverMiddleware(logger *elog.Component, config *Config) restful.FilterFun
 				logger.Error("access", fields...)
 				return
 			}
-			if config.EnableAccessInterceptor {
+			if enableAccessInterceptor {
+				routePath := ctx.SelectedRoutePath()
+				if routePath == "" {
+					routePath = ctx.Req().URL.Path
+				}
+				level := config.accessLogPolicy.Level(ctx.Req().Method, routePath)
+				// 出错和慢请求始终记录，其余请求按路由排除规则和采样率记录
+				if isAccessError(ctx.Context) || slow {
+					if level == "debug" {
+						level = "info"
+					}
+				} else if config.accessLogPolicy.Excluded(ctx.Req().Method, routePath) || !sampled(sampleRate) {
+					return
+				}
 				fields = append(fields,
 					elog.FieldEvent(event),
 					elog.FieldErrAny(ctx.Error()),
 					elog.FieldCode(int32(ctx.StatusCode())),
 				)
-				logger.Info("access", fields...)
+				logAccess(logger, level, "access", fields...)
 			}
 		}()
 		ctx.ProcessFilter()
 	})
 }
 
+func convert2googleResponse(ctx Context) *rpcpb.AttributeContext_Response {
+	return &rpcpb.AttributeContext_Response{
+		Code:    int64(ctx.StatusCode()),
+		Headers: convertHeader(ctx.Header()),
+		Time:    timestamppb.New(time.Now()),
+	}
+}
+
+func convert2googleRequest(r *http.Request) *rpcpb.AttributeContext_Request {
+	return &rpcpb.AttributeContext_Request{
+		Method:  r.Method,
+		Headers: convertHeader(r.Header),
+		Path:    r.URL.Path,
+		Host:    r.Host,
+		Scheme:  r.URL.Scheme,
+		Query:   r.URL.RawQuery,
+		Time:    timestamppb.New(time.Now()),
+	}
+}
+
+func convertHeader(headers http.Header) map[string]string {
+	h := make(map[string]string)
+	for name, val := range headers {
+		h[strings.ToLower(name)] = strings.Join(val, ";")
+	}
+	return h
+}
+
+// checkFilter 判断请求是否符合 AccessInterceptorReqResFilter，需要持有读锁
+func checkFilter(logger *elog.Component, config *Config, ctx Context) bool {
+	if config.aiReqResCelPrg == nil {
+		return true
+	}
+	out, _, err := config.aiReqResCelPrg.Eval(map[string]interface{}{
+		"request":  convert2googleRequest(ctx.Req()),
+		"response": convert2googleResponse(ctx),
+	})
+	if err != nil {
+		logger.Warn("cel eval fail", elog.FieldErr(err))
+	}
+	return out == types.True
+}
+
 // stack returns a nicely formatted stack frame, skipping skip frames.
 func stack(skip int) []byte {
 	buf := new(bytes.Buffer) // the returned data
@@ -0,0 +1,40 @@
+package eref
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRecoverCapturesResponseOnlyWhenLogged(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableAccessInterceptor = false
+	config.AccessInterceptorReqResFilter = `request.path == "/captured"`
+	container := restful.NewContainer()
+	container.Filter(recoverMiddleware(elog.EgoLogger, config))
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/captured").To(RouteContext(func(ctx Context) {
+		_, captured := ctx.Response.ResponseWriter.(*resWriter)
+		_, _ = ctx.Write([]byte(strconv.FormatBool(captured)))
+	})))
+	container.Add(ws)
+
+	captured := func() string {
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/captured", nil))
+		return w.Body.String()
+	}
+	// 只配置了过滤表达式时不缓存响应
+	if got := captured(); got != "false" {
+		t.Errorf("captured without EnableAccessInterceptorRes = %s, want false", got)
+	}
+	config.mu.Lock()
+	config.EnableAccessInterceptorRes = true
+	config.mu.Unlock()
+	if got := captured(); got != "true" {
+		t.Errorf("captured with EnableAccessInterceptorRes = %s, want true", got)
+	}
+}
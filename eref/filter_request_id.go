@@ -0,0 +1,85 @@
+package eref
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"strconv"
+	"time"
+)
+
+// HeaderXRequestID 默认的请求ID header
+const HeaderXRequestID = "X-Request-Id"
+
+// requestIDAttribute 请求ID在 restful.Request 中的属性名
+const requestIDAttribute = "request_id"
+
+// maxRequestIDLength 上游传入的请求ID最大长度，超过则重新生成
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// requestIDMiddleware 读取或生成请求ID，写入上下文并回写到响应header
+func requestIDMiddleware(config *Config) restful.FilterFunction {
+	header := config.RequestIDHeader
+	if header == "" {
+		header = HeaderXRequestID
+	}
+	return Filter(func(ctx FilterContext) {
+		id := ctx.HeaderParameter(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx.SetAttribute(requestIDAttribute, id)
+		ctx.Request.Request = ctx.Req().WithContext(WithRequestID(ctx.Req().Context(), id))
+		ctx.resetLogger()
+		ctx.Response.AddHeader(header, id)
+		ctx.ProcessFilter()
+	})
+}
+
+// RequestIDFromContext 从context中获取请求ID，用于调用下游服务时透传
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// WithRequestID 将请求ID写入context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// fieldRequestID 请求ID日志字段
+func fieldRequestID(id string) elog.Field {
+	return elog.String("rid", id)
+}
+
+// newRequestID 生成32位16进制的请求ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 校验上游传入的请求ID，避免超长或者特殊字符污染日志
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= '0' && ch <= '9', ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
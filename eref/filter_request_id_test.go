@@ -0,0 +1,113 @@
+package eref
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// requestIDServer 注册错误恢复、请求ID中间件的容器，handler 返回请求ID并写一条日志
+func requestIDServer(config *Config) (*restful.Container, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := elog.DefaultContainer().Build(elog.WithZapCore(core))
+	config.accessLogPolicy, _ = newAccessLogPolicy(config)
+	config.redactor, _ = newRedactor(config)
+	container := restful.NewContainer()
+	container.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		req.SetAttribute(componentAttribute, &Component{logger: logger})
+		chain.ProcessFilter(req, resp)
+	})
+	container.Filter(recoverMiddleware(logger, config))
+	if config.EnableRequestID {
+		container.Filter(requestIDMiddleware(config))
+	}
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/id").To(RouteContext(func(ctx Context) {
+		ctx.Log.Info("handled")
+		_, _ = ctx.Write([]byte(ctx.RequestID() + "," + RequestIDFromContext(ctx.Context())))
+	})))
+	container.Add(ws)
+	return container, logs
+}
+
+// serveRequestID 请求并返回响应
+func serveRequestID(container *restful.Container, header, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	if id != "" {
+		req.Header.Set(header, id)
+	}
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	container, logs := requestIDServer(DefaultConfig())
+	w := serveRequestID(container, HeaderXRequestID, "")
+	id := w.Header().Get(HeaderXRequestID)
+	if len(id) != 32 || !validRequestID(id) {
+		t.Fatalf("generated request id = %q", id)
+	}
+	if got := w.Body.String(); got != id+","+id {
+		t.Errorf("handler request id = %q, want %q", got, id+","+id)
+	}
+	// 访问日志和 handler 的请求日志都带有请求ID
+	for _, message := range []string{"access", "handled"} {
+		entries := logs.FilterMessage(message).All()
+		if len(entries) != 1 {
+			t.Fatalf("%s logged %d times", message, len(entries))
+		}
+		if got := entries[0].ContextMap()["rid"]; got != id {
+			t.Errorf("%s rid = %v, want %q", message, got, id)
+		}
+	}
+	if next := serveRequestID(container, HeaderXRequestID, "").Header().Get(HeaderXRequestID); next == id {
+		t.Errorf("request id %q reused", id)
+	}
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	config := DefaultConfig()
+	config.RequestIDHeader = "X-Correlation-Id"
+	container, _ := requestIDServer(config)
+
+	w := serveRequestID(container, "X-Correlation-Id", "upstream-1.a:b_c")
+	if got := w.Header().Get("X-Correlation-Id"); got != "upstream-1.a:b_c" {
+		t.Errorf("response request id = %q, want upstream id", got)
+	}
+	if got := w.Body.String(); got != "upstream-1.a:b_c,upstream-1.a:b_c" {
+		t.Errorf("handler request id = %q", got)
+	}
+	if got := w.Header().Get(HeaderXRequestID); got != "" {
+		t.Errorf("default header %s = %q, want empty", HeaderXRequestID, got)
+	}
+
+	// 非法或超长的请求ID重新生成
+	for _, id := range []string{"a b", "id\n", strings.Repeat("a", maxRequestIDLength+1)} {
+		got := serveRequestID(container, "X-Correlation-Id", id).Header().Get("X-Correlation-Id")
+		if got == id || len(got) != 32 {
+			t.Errorf("upstream id %q: response request id = %q, want regenerated", id, got)
+		}
+	}
+}
+
+func TestRequestIDDisabled(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableRequestID = false
+	container, logs := requestIDServer(config)
+	w := serveRequestID(container, HeaderXRequestID, "upstream")
+	if got := w.Header().Get(HeaderXRequestID); got != "" {
+		t.Errorf("response request id = %q, want empty", got)
+	}
+	if got := w.Body.String(); got != "," {
+		t.Errorf("handler request id = %q, want empty", got)
+	}
+	if _, ok := logs.FilterMessage("access").All()[0].ContextMap()["rid"]; ok {
+		t.Error("access log has rid when disabled")
+	}
+}
@@ -0,0 +1,213 @@
+package eref
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gotomicro/ego/core/elog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名请求的header
+const (
+	HeaderXAccessKey = "X-Access-Key"
+	HeaderXTimestamp = "X-Timestamp"
+	HeaderXNonce     = "X-Nonce"
+	HeaderXSignature = "X-Signature"
+)
+
+const (
+	defaultSignatureMaxSkew     = 5 * time.Minute
+	defaultSignatureMaxBodySize = 10 << 20
+	maxNonceLength              = 128
+)
+
+// signatureAuth HMAC签名鉴权
+type signatureAuth struct {
+	provider    APIKeyProvider
+	nonces      NonceStore
+	maxSkew     time.Duration
+	maxBodySize int64
+	excludes    routeMatcher
+	now         func() time.Time
+}
+
+// newSignatureAuth 根据配置构建签名鉴权
+func newSignatureAuth(config *Config) (*signatureAuth, error) {
+	excludes, err := newRouteMatcher("SignatureExcludeRoutes", config.SignatureExcludeRoutes)
+	if err != nil {
+		return nil, err
+	}
+	a := &signatureAuth{
+		provider:    apiKeyProvider(config),
+		nonces:      config.nonceStore,
+		maxSkew:     config.SignatureMaxSkew,
+		maxBodySize: config.SignatureMaxBodySize,
+		excludes:    excludes,
+		now:         time.Now,
+	}
+	if a.nonces == nil {
+		a.nonces = NewMemoryNonceStore()
+	}
+	if a.maxSkew <= 0 {
+		a.maxSkew = defaultSignatureMaxSkew
+	}
+	if a.maxBodySize <= 0 {
+		a.maxBodySize = defaultSignatureMaxBodySize
+	}
+	return a, nil
+}
+
+// SignRequest 使用HMAC-SHA256对请求签名，供调用方使用
+// 签名内容见 canonicalRequest，调用后请求body会被重置，可以正常发送
+func SignRequest(req *http.Request, accessKey, secret string) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := newRequestID()
+	req.Header.Set(HeaderXAccessKey, accessKey)
+	req.Header.Set(HeaderXTimestamp, timestamp)
+	req.Header.Set(HeaderXNonce, nonce)
+	req.Header.Set(HeaderXSignature, sign(secret, canonicalRequest(req.Method, req.URL, body, timestamp, nonce)))
+	return nil
+}
+
+// canonicalRequest 待签名的字符串，每行依次为
+// 请求方法、转义后的路径、按key和value排序的query、body的sha256、时间戳、nonce
+func canonicalRequest(method string, u *url.URL, body []byte, timestamp, nonce string) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		strings.Join(pairs, "&"),
+		hex.EncodeToString(bodyHash[:]),
+		timestamp,
+		nonce,
+	}, "\n")
+}
+
+func sign(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify 校验签名请求，返回调用方凭证
+func (a *signatureAuth) verify(ctx Context) (*APIKey, error) {
+	accessKey := ctx.HeaderParameter(HeaderXAccessKey)
+	timestamp := ctx.HeaderParameter(HeaderXTimestamp)
+	nonce := ctx.HeaderParameter(HeaderXNonce)
+	signature := ctx.HeaderParameter(HeaderXSignature)
+	if accessKey == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, fmt.Errorf("missing signature headers, %w", errMissingCredential)
+	}
+	if len(nonce) > maxNonceLength {
+		return nil, errors.New("nonce too long")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if skew := a.now().Sub(time.Unix(ts, 0)); skew > a.maxSkew || skew < -a.maxSkew {
+		return nil, fmt.Errorf("timestamp %q out of range", timestamp)
+	}
+	apiKey, err := a.provider.APIKey(ctx.Context(), accessKey)
+	if err != nil {
+		return nil, signatureBackendError{err: err}
+	}
+	if apiKey == nil || apiKey.Secret == "" {
+		return nil, fmt.Errorf("unknown access key %q", accessKey)
+	}
+	body, err := ctx.readBody(a.maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	expected := sign(apiKey.Secret, canonicalRequest(ctx.Req().Method, ctx.Req().URL, body, timestamp, nonce))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, errors.New("signature mismatch")
+	}
+	// 签名校验通过后再记录nonce，避免伪造请求占用nonce
+	ok, err := a.nonces.Use(ctx.Context(), accessKey+"|"+nonce, 2*a.maxSkew)
+	if err != nil {
+		return nil, signatureBackendError{err: err}
+	}
+	if !ok {
+		return nil, fmt.Errorf("nonce %q already used", nonce)
+	}
+	return apiKey, nil
+}
+
+// skip implements authenticator
+func (a *signatureAuth) skip(ctx Context) bool {
+	return skipAuth(ctx, a.excludes)
+}
+
+// signatureBackendError 查询密钥、记录nonce失败，和 API key 鉴权一样返回500，不当作凭证错误
+type signatureBackendError struct {
+	err error
+}
+
+func (e signatureBackendError) Error() string {
+	return e.err.Error()
+}
+
+func (e signatureBackendError) Unwrap() error {
+	return e.err
+}
+
+// authenticate implements authenticator，校验失败返回401，body超过限制返回413，后端异常返回500
+func (a *signatureAuth) authenticate(ctx *Context) *authError {
+	apiKey, err := a.verify(*ctx)
+	if errors.Is(err, errBodyTooLarge) {
+		return newAuthError(http.StatusRequestEntityTooLarge, err)
+	}
+	var backendErr signatureBackendError
+	if errors.As(err, &backendErr) {
+		ctx.Log.Warn("signature auth backend error", elog.FieldErr(err))
+		return newAuthError(http.StatusInternalServerError, err)
+	}
+	if err != nil {
+		ctx.Log.Debug("signature auth fail", elog.FieldErr(err))
+		return newAuthError(http.StatusUnauthorized, err)
+	}
+	ctx.setAPIKey(apiKey)
+	return nil
+}
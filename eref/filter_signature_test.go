@@ -0,0 +1,87 @@
+package eref_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signedRequest 使用 eref.SignRequest 签名后构建测试请求
+func signedRequest(t *testing.T, s *ereftest.Server, method, path string, body []byte, secret string) *ereftest.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if err := eref.SignRequest(req, "key-billing", secret); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	r := s.NewRequest(method, path).Body("application/json", body)
+	for _, key := range []string{eref.HeaderXAccessKey, eref.HeaderXTimestamp, eref.HeaderXNonce, eref.HeaderXSignature} {
+		r.Header(key, req.Header.Get(key))
+	}
+	return r
+}
+
+func TestSignature(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableSignature":      true,
+		"APIKeys":              testAPIKeys,
+		"SignatureMaxBodySize": 64,
+	})
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.POST("/orders").To(eref.RouteContext(func(ctx eref.Context) {
+		// 校验签名后 handler 仍然可以读取body
+		body, _ := io.ReadAll(ctx.Req().Body)
+		_, _ = ctx.Write(body)
+	})))
+	s.Add(ws)
+
+	body := []byte(`{"amount":100}`)
+	signed := signedRequest(t, s, http.MethodPost, "/api/orders?from=test", body, "secret-billing")
+	signed.Do().ExpectStatus(http.StatusOK).ExpectBody(string(body))
+	// 相同的nonce不能重放
+	signed.Do().ExpectStatus(http.StatusUnauthorized)
+
+	signedRequest(t, s, http.MethodPost, "/api/orders", body, "wrong-secret").Do().
+		ExpectStatus(http.StatusUnauthorized)
+
+	tampered := signedRequest(t, s, http.MethodPost, "/api/orders", body, "secret-billing")
+	tampered.Body("application/json", []byte(`{"amount":1}`)).Do().
+		ExpectStatus(http.StatusUnauthorized)
+
+	s.POST("/api/orders").Body("application/json", body).Do().
+		ExpectStatus(http.StatusUnauthorized)
+
+	large := bytes.Repeat([]byte("a"), 65)
+	signedRequest(t, s, http.MethodPost, "/api/orders", large, "secret-billing").Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge)
+}
+
+// errNonceStore 记录nonce失败
+type errNonceStore struct{}
+
+func (errNonceStore) Use(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("redis unavailable")
+}
+
+func TestSignatureBackendError(t *testing.T) {
+	// 后端异常和 API key 鉴权一样返回500，不当作凭证错误
+	s := loadServer(t, map[string]interface{}{"EnableSignature": true}, eref.WithAPIKeyProvider(errAPIKeyProvider{}))
+	ws := eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+	signedRequest(t, s, http.MethodGet, "/api/me", nil, "secret-billing").Do().
+		ExpectStatus(http.StatusInternalServerError)
+
+	s = loadServer(t, map[string]interface{}{"EnableSignature": true, "APIKeys": testAPIKeys}, eref.WithNonceStore(errNonceStore{}))
+	ws = eref.NewRoute("/api")
+	identityRoute(ws, "/me")
+	s.Add(ws)
+	signedRequest(t, s, http.MethodGet, "/api/me", nil, "secret-billing").Do().
+		ExpectStatus(http.StatusInternalServerError)
+}
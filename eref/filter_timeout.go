@@ -10,9 +10,9 @@ import (
 // timeout middleware wraps the request context with a timeout
 func timeoutMiddleware(timeout time.Duration) restful.FilterFunction {
 	return Filter(func(c FilterContext) {
-		// 若无自定义超时设置，默认设置超时
+		// 若无自定义超时设置，默认设置超时，通过 Streaming 标记的流式路由不设置超时
 		_, ok := c.Req().Context().Deadline()
-		if ok {
+		if ok || isStreaming(c.Request) {
 			c.ProcessFilter()
 			return
 		}
@@ -24,6 +24,7 @@ func timeoutMiddleware(timeout time.Duration) restful.FilterFunction {
 			if ctx.Err() == context.DeadlineExceeded {
 				// write response and abort the request
 				c.Response.WriteHeader(http.StatusGatewayTimeout)
+				serverMetrics.incTimeout(c.Req().Method + "." + c.SelectedRoutePath())
 				c.FilterChain.Index = 63
 			}
 			//cancel to clear resources after finished
@@ -1,39 +1,85 @@
 package eref
 
 import (
+	"context"
+	"fmt"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/gotomicro/ego/core/eapp"
 	"github.com/gotomicro/ego/core/etrace"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
+	"net/http"
 )
 
-// traceServerInterceptor 开启链路追踪，默认开启
-func traceServerInterceptor() restful.FilterFunction {
+// isTracerRegistered 是否配置了链路追踪
+// ego 通过 etrace.SetGlobalTracer 注册，直接使用 otel.SetTracerProvider 注册时需要开启 EnableOtelTracerProvider
+func isTracerRegistered(config *Config) bool {
+	return etrace.IsGlobalTracerRegistered() || config.EnableOtelTracerProvider
+}
+
+// extractTraceID 从context中获取trace id，不依赖 etrace 的注册状态
+func extractTraceID(ctx context.Context) string {
+	span := trace.SpanContextFromContext(ctx)
+	if span.HasTraceID() {
+		return span.TraceID().String()
+	}
+	return ""
+}
+
+// traceServerInterceptor 开启链路追踪，默认开启，http.url 中的凭证查询参数会打码
+func traceServerInterceptor(excludes routeMatcher, redactor *redactor) restful.FilterFunction {
 	tracer := etrace.NewTracer(trace.SpanKindServer)
 	attrs := []attribute.KeyValue{
 		semconv.RPCSystemKey.String("http"),
 	}
 	return Filter(func(c FilterContext) {
+		if excludes.Match(c.Req().Method, c.Request.SelectedRoutePath()) {
+			c.ProcessFilter()
+			return
+		}
 		// 该方法会在v0.9.0移除
 		etrace.CompatibleExtractHTTPTraceID(c.Req().Header)
 		ctx, span := tracer.Start(c.Context.Context(), c.Req().Method+"."+c.Request.SelectedRoutePath(), propagation.HeaderCarrier(c.Req().Header), trace.WithAttributes(attrs...))
 		span.SetAttributes(
-			semconv.HTTPURLKey.String(c.Req().URL.String()),
+			semconv.HTTPURLKey.String(redactor.URI(c.Req().URL)),
 			semconv.HTTPTargetKey.String(c.Req().URL.Path),
 			semconv.HTTPMethodKey.String(c.Req().Method),
 			semconv.HTTPUserAgentKey.String(c.Req().UserAgent()),
 			semconv.HTTPClientIPKey.String(c.ClientIP()),
 			etrace.CustomTag("http.full_path", c.Request.SelectedRoutePath()),
 		)
+		if c.Req().ContentLength > 0 {
+			span.SetAttributes(semconv.HTTPRequestContentLengthKey.Int64(c.Req().ContentLength))
+		}
 		c.Context.Request.Request = c.Req().WithContext(ctx)
+		c.resetLogger()
 		c.Response.AddHeader(eapp.EgoTraceIDName(), span.SpanContext().TraceID().String())
+		defer func() {
+			// panic 记录到span后继续抛出，由 recoverMiddleware 处理
+			if rec := recover(); rec != nil {
+				span.AddEvent("panic", trace.WithAttributes(
+					attribute.String("exception.type", fmt.Sprintf("%T", rec)),
+					attribute.String("exception.message", fmt.Sprint(rec)),
+					attribute.String("exception.stacktrace", string(stack(3))),
+				))
+				span.SetAttributes(semconv.HTTPStatusCodeKey.Int64(http.StatusInternalServerError))
+				span.SetStatus(codes.Error, "panic")
+				span.End()
+				panic(rec)
+			}
+		}()
 		c.ProcessFilter()
 		span.SetAttributes(
 			semconv.HTTPStatusCodeKey.Int64(int64(c.Response.StatusCode())),
+			semconv.HTTPResponseContentLengthKey.Int(c.Response.ContentLength()),
 		)
+		// 服务端只有5xx认为是错误，4xx是客户端的问题
+		if c.Response.StatusCode() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(c.Response.StatusCode()))
+		}
 		span.End()
 	})
 }
@@ -0,0 +1,223 @@
+package eref_test
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/ego-plugin/server/eref"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/eapp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// recordedSpan 记录属性、状态、事件的span
+type recordedSpan struct {
+	trace.Span
+	recorder *spanRecorder
+	sc       trace.SpanContext
+	name     string
+	attrs    map[attribute.Key]attribute.Value
+	status   codes.Code
+	events   []string
+	ended    bool
+}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func (s *recordedSpan) IsRecording() bool { return true }
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordedSpan) AddEvent(name string, _ ...trace.EventOption) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.ended = true
+}
+
+// spanRecorder 只通过 otel.SetTracerProvider 注册的内存 TracerProvider
+type spanRecorder struct {
+	mu    sync.Mutex
+	seq   uint64
+	spans []*recordedSpan
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer { return r }
+
+func (r *spanRecorder) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(traceID[8:], r.seq)
+	binary.BigEndian.PutUint64(spanID[:], r.seq)
+	if parent := trace.SpanContextFromContext(ctx); parent.HasTraceID() {
+		traceID = parent.TraceID()
+	}
+	span := &recordedSpan{
+		Span:     trace.SpanFromContext(context.Background()),
+		recorder: r,
+		sc:       trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}),
+		name:     name,
+		attrs:    make(map[attribute.Key]attribute.Value),
+	}
+	config := trace.NewSpanStartConfig(opts...)
+	for _, attr := range config.Attributes() {
+		span.attrs[attr.Key] = attr.Value
+	}
+	r.spans = append(r.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// take 取出记录的span
+func (r *spanRecorder) take() []*recordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := r.spans
+	r.spans = nil
+	return spans
+}
+
+var (
+	spans     = &spanRecorder{}
+	spansOnce sync.Once
+)
+
+// recordSpans 注册全局 TracerProvider，otel 的全局代理只能设置一次
+func recordSpans() *spanRecorder {
+	spansOnce.Do(func() { otel.SetTracerProvider(spans) })
+	spans.take()
+	return spans
+}
+
+// takeSpan 取出唯一的span
+func takeSpan(t *testing.T, r *spanRecorder) *recordedSpan {
+	t.Helper()
+	got := r.take()
+	if len(got) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(got))
+	}
+	return got[0]
+}
+
+func traceRoutes() *restful.WebService {
+	ws := eref.NewRoute("/api/trace")
+	ws.Route(ws.GET("/ok").To(eref.RouteContext(func(ctx eref.Context) {
+		_, _ = ctx.Write([]byte("pong"))
+	})))
+	ws.Route(ws.GET("/fail").To(eref.RouteContext(func(ctx eref.Context) {
+		ctx.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	ws.Route(ws.GET("/bad").To(eref.RouteContext(func(ctx eref.Context) {
+		ctx.WriteHeader(http.StatusBadRequest)
+	})))
+	ws.Route(ws.GET("/panic").To(eref.RouteContext(func(ctx eref.Context) {
+		panic("boom")
+	})))
+	ws.Route(ws.GET("/health").To(eref.RouteContext(func(ctx eref.Context) {})))
+	return ws
+}
+
+func TestTraceOtelProvider(t *testing.T) {
+	recorder := recordSpans()
+	// 没有通过 etrace 注册，也没有开启 EnableOtelTracerProvider 时不记录链路
+	s := loadServer(t, map[string]interface{}{})
+	s.Add(traceRoutes())
+	if got := s.GET("/api/trace/ok").Do().ExpectStatus(http.StatusOK).Header(eapp.EgoTraceIDName()); got != "" {
+		t.Errorf("trace id header = %q, want empty", got)
+	}
+	if got := recorder.take(); len(got) != 0 {
+		t.Errorf("recorded %d spans without tracer provider", len(got))
+	}
+
+	s = loadServer(t, map[string]interface{}{"EnableOtelTracerProvider": true})
+	s.Add(traceRoutes())
+	res := s.GET("/api/trace/ok").Do().ExpectStatus(http.StatusOK)
+	span := takeSpan(t, recorder)
+	if span.name != "GET./api/trace/ok" || !span.ended {
+		t.Errorf("span name = %q, ended = %v", span.name, span.ended)
+	}
+	if got, want := res.Header(eapp.EgoTraceIDName()), span.sc.TraceID().String(); got != want {
+		t.Errorf("trace id header = %q, want %q", got, want)
+	}
+	if span.status != codes.Unset {
+		t.Errorf("span status = %v, want unset", span.status)
+	}
+	if got := span.attrs["http.status_code"].AsInt64(); got != http.StatusOK {
+		t.Errorf("http.status_code = %d", got)
+	}
+	if got := span.attrs["http.response_content_length"].AsInt64(); got != 4 {
+		t.Errorf("http.response_content_length = %d, want 4", got)
+	}
+}
+
+func TestTraceStatusAndPanic(t *testing.T) {
+	recorder := recordSpans()
+	s := loadServer(t, map[string]interface{}{"EnableOtelTracerProvider": true})
+	s.Add(traceRoutes())
+
+	// 4xx 是客户端的问题，不标记为错误
+	s.GET("/api/trace/bad").Do().ExpectStatus(http.StatusBadRequest)
+	if span := takeSpan(t, recorder); span.status != codes.Unset {
+		t.Errorf("4xx span status = %v, want unset", span.status)
+	}
+	s.GET("/api/trace/fail").Do().ExpectStatus(http.StatusServiceUnavailable)
+	if span := takeSpan(t, recorder); span.status != codes.Error {
+		t.Errorf("5xx span status = %v, want error", span.status)
+	}
+	s.GET("/api/trace/panic").Do().ExpectStatus(http.StatusInternalServerError)
+	span := takeSpan(t, recorder)
+	if span.status != codes.Error || !span.ended {
+		t.Errorf("panic span status = %v, ended = %v", span.status, span.ended)
+	}
+	if len(span.events) != 1 || span.events[0] != "panic" {
+		t.Errorf("panic span events = %v", span.events)
+	}
+	if got := span.attrs["http.status_code"].AsInt64(); got != http.StatusInternalServerError {
+		t.Errorf("panic http.status_code = %d", got)
+	}
+}
+
+func TestTracePropagationAndExclude(t *testing.T) {
+	recorder := recordSpans()
+	s := loadServer(t, map[string]interface{}{
+		"EnableOtelTracerProvider": true,
+		"TraceExcludeRoutes":       []string{"/api/trace/health"},
+	})
+	s.Add(traceRoutes())
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	s.GET("/api/trace/ok").Header("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01").Do().
+		ExpectHeader(eapp.EgoTraceIDName(), traceID)
+	if got := takeSpan(t, recorder).sc.TraceID().String(); got != traceID {
+		t.Errorf("span trace id = %q, want %q", got, traceID)
+	}
+
+	s.GET("/api/trace/health").Do().ExpectStatus(http.StatusOK)
+	if got := recorder.take(); len(got) != 0 {
+		t.Errorf("excluded route recorded %d spans", len(got))
+	}
+}
@@ -0,0 +1,227 @@
+package eref
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 健康检查默认路径
+const (
+	defaultHealthzPath = "/healthz"
+	defaultReadyzPath  = "/readyz"
+	defaultLivezPath   = "/livez"
+)
+
+// 健康检查状态
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// errShuttingDown 服务正在关闭，readiness 失败
+var errShuttingDown = errors.New("server is shutting down")
+
+// HealthCheckFunc 健康检查函数，返回error表示不健康
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthCheck 健康检查
+type HealthCheck struct {
+	Name     string          // 名称，重复注册时替换
+	Check    HealthCheckFunc // 检查函数
+	Timeout  time.Duration   // 超时时间，默认 HealthCheckTimeout
+	Critical bool            // 失败时接口返回503，非关键检查失败只体现在详情中
+	Liveness bool            // 是否同时用于 /livez，全部检查都用于 /healthz、/readyz
+}
+
+// HealthCheckResult 单个检查的结果
+type HealthCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// HealthReport 健康检查接口的返回
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// healthEntry 注册的检查以及缓存的结果
+type healthEntry struct {
+	check  HealthCheck
+	mu     sync.Mutex
+	result *HealthCheckResult
+}
+
+// run 执行检查，缓存未过期时直接返回，并发调用时只执行一次
+func (e *healthEntry) run(ctx context.Context, ttl time.Duration) HealthCheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.result != nil && time.Since(e.result.CheckedAt) < ttl {
+		return *e.result
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.check.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("health check panic: %v", rec)
+			}
+		}()
+		done <- e.check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := HealthCheckResult{
+		Name:      e.check.Name,
+		Status:    HealthStatusOK,
+		Critical:  e.check.Critical,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status, result.Error = HealthStatusFail, err.Error()
+	}
+	e.result = &result
+	return result
+}
+
+// healthChecker 健康检查，并发安全
+type healthChecker struct {
+	mu           sync.RWMutex
+	entries      []*healthEntry
+	timeout      time.Duration
+	cacheTTL     time.Duration
+	shuttingDown int32
+}
+
+func newHealthChecker(config *Config) *healthChecker {
+	h := &healthChecker{
+		timeout:  config.HealthCheckTimeout,
+		cacheTTL: config.HealthCheckCacheTTL,
+	}
+	if h.timeout <= 0 {
+		h.timeout = time.Second
+	}
+	return h
+}
+
+// register 注册检查，同名检查会被替换
+func (h *healthChecker) register(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = h.timeout
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, entry := range h.entries {
+		if entry.check.Name == check.Name {
+			h.entries[i] = &healthEntry{check: check}
+			return
+		}
+	}
+	h.entries = append(h.entries, &healthEntry{check: check})
+}
+
+// setShuttingDown 标记服务正在关闭，之后 readiness 始终失败
+func (h *healthChecker) setShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// isShuttingDown 服务是否正在关闭
+func (h *healthChecker) isShuttingDown() bool {
+	return atomic.LoadInt32(&h.shuttingDown) == 1
+}
+
+// report 并发执行检查，filter 为nil时执行全部检查
+func (h *healthChecker) report(ctx context.Context, filter func(HealthCheck) bool) HealthReport {
+	h.mu.RLock()
+	entries := make([]*healthEntry, 0, len(h.entries))
+	for _, entry := range h.entries {
+		if filter == nil || filter(entry.check) {
+			entries = append(entries, entry)
+		}
+	}
+	h.mu.RUnlock()
+
+	report := HealthReport{Status: HealthStatusOK, Checks: make([]HealthCheckResult, len(entries))}
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *healthEntry) {
+			defer wg.Done()
+			report.Checks[i] = entry.run(ctx, h.cacheTTL)
+		}(i, entry)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status != HealthStatusOK && result.Critical {
+			report.Status = HealthStatusFail
+		}
+	}
+	return report
+}
+
+// handler 返回健康检查接口，readiness 为true时服务关闭后返回失败
+func (h *healthChecker) handler(filter func(HealthCheck) bool, readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.report(r.Context(), filter)
+		if readiness && h.isShuttingDown() {
+			report.Status = HealthStatusFail
+			report.Checks = append(report.Checks, HealthCheckResult{
+				Name:      "shutdown",
+				Status:    HealthStatusFail,
+				Critical:  true,
+				Error:     errShuttingDown.Error(),
+				Duration:  time.Duration(0).String(),
+				CheckedAt: time.Now(),
+			})
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", restful.MIME_JSON)
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != HealthStatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(data)
+	}
+}
+
+// RegisterHealthCheck 注册健康检查，可以在启动前后任意时刻调用
+func (c *Component) RegisterHealthCheck(check HealthCheck) {
+	c.health.register(check)
+}
+
+// Health 执行全部健康检查
+func (c *Component) Health(ctx context.Context) HealthReport {
+	return c.health.report(ctx, nil)
+}
+
+// Ready 服务是否可以接收流量，正在关闭或者关键检查失败时返回false
+func (c *Component) Ready(ctx context.Context) bool {
+	return !c.health.isShuttingDown() && c.health.report(ctx, nil).Status == HealthStatusOK
+}
+
+// livenessCheck 用于 /livez 的检查
+func livenessCheck(check HealthCheck) bool {
+	return check.Liveness
+}
@@ -0,0 +1,146 @@
+package eref_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ego-plugin/server/eref"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthResults 按名称索引检查结果
+func healthResults(report eref.HealthReport) map[string]eref.HealthCheckResult {
+	results := make(map[string]eref.HealthCheckResult, len(report.Checks))
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+	return results
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	// 健康检查不经过鉴权等 filter
+	s := loadServer(t, map[string]interface{}{
+		"EnableJWT":           true,
+		"JWTSecret":           testJWTSecret,
+		"HealthCheckCacheTTL": "0s",
+	})
+	var cacheDown int32
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "db", Critical: true, Liveness: true, Check: func(ctx context.Context) error {
+		return nil
+	}})
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "cache", Critical: true, Check: func(ctx context.Context) error {
+		if atomic.LoadInt32(&cacheDown) == 1 {
+			return errors.New("cache down")
+		}
+		return nil
+	}})
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "search", Check: func(ctx context.Context) error {
+		return errors.New("search degraded")
+	}})
+
+	// 非关键检查失败只体现在详情中
+	var report eref.HealthReport
+	s.GET("/healthz").Do().ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", "application/json").
+		ExpectHeader("Cache-Control", "no-store").
+		DecodeJSON(&report)
+	results := healthResults(report)
+	if report.Status != eref.HealthStatusOK || len(results) != 3 {
+		t.Fatalf("healthz report = %+v", report)
+	}
+	if r := results["search"]; r.Status != eref.HealthStatusFail || r.Critical || r.Error != "search degraded" {
+		t.Errorf("search result = %+v", r)
+	}
+
+	atomic.StoreInt32(&cacheDown, 1)
+	for _, path := range []string{"/healthz", "/readyz"} {
+		s.GET(path).Do().ExpectStatus(http.StatusServiceUnavailable).DecodeJSON(&report)
+		if report.Status != eref.HealthStatusFail || healthResults(report)["cache"].Error != "cache down" {
+			t.Errorf("%s report = %+v", path, report)
+		}
+	}
+	// livez 只执行 Liveness 检查
+	s.GET("/livez").Do().ExpectStatus(http.StatusOK).DecodeJSON(&report)
+	if len(report.Checks) != 1 || report.Checks[0].Name != "db" {
+		t.Errorf("livez checks = %+v", report.Checks)
+	}
+	if s.Component.Ready(context.Background()) {
+		t.Error("Ready with failing critical check")
+	}
+
+	// 同名检查替换
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "cache", Critical: true, Check: func(ctx context.Context) error {
+		return nil
+	}})
+	s.GET("/readyz").Do().ExpectStatus(http.StatusOK)
+	if !s.Component.Ready(context.Background()) {
+		t.Error("not Ready after replacing failing check")
+	}
+}
+
+func TestHealthCheckTimeoutAndPanic(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"HealthCheckTimeout": "50ms"})
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "slow", Critical: true, Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	}})
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "panic", Check: func(ctx context.Context) error {
+		panic("boom")
+	}})
+
+	start := time.Now()
+	report := s.Component.Health(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("health report took %v, want about the check timeout", elapsed)
+	}
+	results := healthResults(report)
+	if report.Status != eref.HealthStatusFail || results["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("slow result = %+v", results["slow"])
+	}
+	if r := results["panic"]; r.Status != eref.HealthStatusFail || r.Error != "health check panic: boom" {
+		t.Errorf("panic result = %+v", r)
+	}
+}
+
+func TestHealthCheckCache(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"HealthCheckCacheTTL": "1m"})
+	var calls int32
+	s.Component.RegisterHealthCheck(eref.HealthCheck{Name: "db", Check: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}})
+	for i := 0; i < 3; i++ {
+		s.GET("/healthz").Do().ExpectStatus(http.StatusOK)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("check called %d times within cache ttl, want 1", got)
+	}
+}
+
+func TestHealthReadyzShuttingDown(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"ReadyzPath": "/ready"})
+	s.GET("/ready").Do().ExpectStatus(http.StatusOK)
+	if err := s.Component.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("GracefulStop: %v", err)
+	}
+	var report eref.HealthReport
+	s.GET("/ready").Do().ExpectStatus(http.StatusServiceUnavailable).DecodeJSON(&report)
+	if r := healthResults(report)["shutdown"]; r.Status != eref.HealthStatusFail || !r.Critical {
+		t.Errorf("shutdown result = %+v", r)
+	}
+	s.GET("/healthz").Do().ExpectStatus(http.StatusOK)
+	s.GET("/livez").Do().ExpectStatus(http.StatusOK)
+	if s.Component.Ready(context.Background()) {
+		t.Error("Ready after GracefulStop")
+	}
+}
+
+func TestHealthDisabled(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableHealthCheck": false})
+	for _, path := range []string{"/healthz", "/readyz", "/livez"} {
+		s.GET(path).Do().ExpectStatus(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,57 @@
+package eref
+
+import (
+	"errors"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/quic-go/quic-go/http3"
+	"net"
+	"net/http"
+)
+
+// listenHTTP3 监听HTTP/3的UDP地址，默认和主listener的地址相同
+// 开启继承时和TCP listener一样优先使用 systemd 或者平滑重启的父进程传递的socket
+func (c *Component) listenHTTP3() (net.PacketConn, error) {
+	if c.tlsConfig == nil {
+		return nil, errors.New("invalid http3 config, EnableTLS is required")
+	}
+	address := c.config.HTTP3Address
+	if address == "" {
+		// 端口为0时使用TCP实际分配的端口
+		address = c.listener.Addr().String()
+	}
+	if c.config.EnableListenerInherit || c.config.EnableGracefulRestart {
+		s, err := inheritPacketConn(c.name, address)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			c.logger.Info("inherit http3 conn", elog.FieldAddr(s.packetConn.LocalAddr().String()), elog.Any("parent", s.parent))
+			return s.packetConn, nil
+		}
+	}
+	return net.ListenPacket("udp", address)
+}
+
+// newHTTP3Server HTTP/3服务，和TCP服务使用同一个 restful 容器和TLS配置
+func (c *Component) newHTTP3Server() *http3.Server {
+	return &http3.Server{
+		Handler:   c.Handler(),
+		TLSConfig: http3.ConfigureTLSConfig(c.tlsConfig.Clone()),
+	}
+}
+
+// altSvcHandler TCP服务的响应中通过 Alt-Svc 告知客户端可以使用HTTP/3
+func altSvcHandler(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = h3.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HTTP3Addr HTTP/3监听的UDP地址，未开启 EnableHTTP3 时返回nil
+func (c *Component) HTTP3Addr() net.Addr {
+	if c.http3Conn == nil {
+		return nil
+	}
+	return c.http3Conn.LocalAddr()
+}
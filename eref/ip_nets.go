@@ -0,0 +1,50 @@
+package eref
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ipNets CIDR集合，也支持单个IP
+type ipNets []*net.IPNet
+
+// parseIPNets 解析CIDR列表，name 为配置项名称，用于错误提示
+func parseIPNets(name string, list []string) (ipNets, error) {
+	nets := make(ipNets, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid %s %q", name, item)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, %w", name, item, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Contains 是否包含ip，ip不合法时返回false
+func (n ipNets) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range n {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
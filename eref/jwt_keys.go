@@ -0,0 +1,217 @@
+package eref
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksMinRefreshInterval 遇到未知kid时强制刷新JWKS的最小间隔，避免伪造kid打爆JWKS服务
+const jwksMinRefreshInterval = 10 * time.Second
+
+// jwks JSON Web Key Set 缓存，支持本地文件和 http(s) 地址
+type jwks struct {
+	source          string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+
+	fetching    sync.Mutex // 保证同一时间只有一个请求在加载
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// jsonWebKey 单个JWK，只解析校验签名需要的字段
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func newJWKS(source string, refreshInterval time.Duration) *jwks {
+	if refreshInterval <= 0 {
+		refreshInterval = 10 * time.Minute
+	}
+	return &jwks{
+		source:          source,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 5 * time.Second},
+		keys:            make(map[string]interface{}),
+	}
+}
+
+// key 根据kid查找公钥，缓存过期或者kid不存在时刷新
+func (j *jwks) key(ctx context.Context, kid string) (interface{}, error) {
+	j.mu.RLock()
+	key, ok := j.lookup(kid)
+	fresh := time.Since(j.fetchedAt) < j.refreshInterval
+	j.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+	// 刷新失败时继续使用旧的公钥
+	if err := j.refresh(ctx); err != nil && !ok {
+		return nil, err
+	}
+	j.mu.RLock()
+	key, ok = j.lookup(kid)
+	j.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("jwks key %q not found", kid)
+	}
+	return key, nil
+}
+
+// lookup 查找公钥，token没有kid并且JWKS只有一个key时直接使用，需要持有读锁
+func (j *jwks) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// refresh 重新加载JWKS，并发刷新时只有一个请求真正去加载，两次加载至少间隔 jwksMinRefreshInterval
+func (j *jwks) refresh(ctx context.Context) error {
+	j.fetching.Lock()
+	defer j.fetching.Unlock()
+	if time.Since(j.lastAttempt) < jwksMinRefreshInterval {
+		return j.lastErr
+	}
+	j.lastAttempt = time.Now()
+	j.lastErr = nil
+	data, err := j.load(ctx)
+	if err == nil {
+		var keys map[string]interface{}
+		if keys, err = parseJWKS(data); err == nil {
+			j.mu.Lock()
+			j.keys = keys
+			j.fetchedAt = time.Now()
+			j.mu.Unlock()
+			return nil
+		}
+	}
+	j.lastErr = fmt.Errorf("load jwks %q fail, %w", j.source, err)
+	return j.lastErr
+}
+
+func (j *jwks) load(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(j.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseJWKS 解析JWKS，忽略不支持的key类型和非签名用途的key
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q, %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return decodeBase64URL(k.K)
+	}
+	return nil, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// parsePublicKeyPEM 解析PEM格式的RSA、ECDSA公钥或证书
+func parsePublicKeyPEM(data []byte) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	key, err := jwt.ParseECPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a rsa or ecdsa public key")
+	}
+	return key, nil
+}
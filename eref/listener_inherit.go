@@ -0,0 +1,156 @@
+package eref
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 继承监听socket的环境变量
+const (
+	envListenPID     = "LISTEN_PID"       // systemd socket activation，目标进程pid
+	envListenFDs     = "LISTEN_FDS"       // systemd socket activation，socket数量
+	envListenFDNames = "LISTEN_FDNAMES"   // systemd socket activation，socket名称，冒号分隔
+	envInheritFDs    = "EREF_INHERIT_FDS" // 平滑重启时父进程传递的socket数量
+	listenFDsStart   = 3                  // 继承的socket从fd 3开始
+)
+
+// inheritedSocket 继承的监听socket，TCP、unix socket 为 listener，UDP 为 packetConn
+type inheritedSocket struct {
+	listener   net.Listener
+	packetConn net.PacketConn
+	name       string // systemd FileDescriptorName
+	parent     bool   // 是否来自平滑重启的父进程
+}
+
+// addr 监听地址
+func (s *inheritedSocket) addr() net.Addr {
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return s.packetConn.LocalAddr()
+}
+
+var (
+	inheritOnce    sync.Once
+	inheritMu      sync.Mutex
+	inheritSockets []*inheritedSocket
+	inheritErr     error
+)
+
+// parseInheritedSockets 解析环境变量中继承的socket，解析后清理环境变量，避免再传给子进程
+func parseInheritedSockets() ([]*inheritedSocket, error) {
+	var (
+		count  int
+		names  []string
+		parent bool
+		err    error
+	)
+	if fds := os.Getenv(envInheritFDs); fds != "" {
+		parent = true
+		if count, err = strconv.Atoi(fds); err != nil {
+			return nil, fmt.Errorf("invalid %s %q, %w", envInheritFDs, fds, err)
+		}
+	} else if os.Getenv(envListenPID) == strconv.Itoa(os.Getpid()) {
+		fds := os.Getenv(envListenFDs)
+		if count, err = strconv.Atoi(fds); err != nil {
+			return nil, fmt.Errorf("invalid %s %q, %w", envListenFDs, fds, err)
+		}
+		if fdNames := os.Getenv(envListenFDNames); fdNames != "" {
+			names = strings.Split(fdNames, ":")
+		}
+	}
+	for _, key := range []string{envInheritFDs, envListenPID, envListenFDs, envListenFDNames} {
+		_ = os.Unsetenv(key)
+	}
+
+	sockets := make([]*inheritedSocket, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		f := os.NewFile(uintptr(fd), "listener")
+		s := &inheritedSocket{parent: parent}
+		// FileListener、FilePacketConn 会复制fd，原fd可以关闭
+		s.listener, err = net.FileListener(f)
+		if err != nil {
+			// UDP socket，例如 HTTP/3
+			var pcErr error
+			if s.packetConn, pcErr = net.FilePacketConn(f); pcErr != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("inherit listener fd %d, %w", fd, err)
+			}
+		}
+		_ = f.Close()
+		if i < len(names) {
+			s.name = names[i]
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, nil
+}
+
+// inheritListener 取出名称或地址匹配的继承listener，没有匹配时返回nil
+// 名称对应 systemd 的 FileDescriptorName，一般配置为组件名
+func inheritListener(name, address string) (*inheritedSocket, error) {
+	return inheritSocket(name, address, false)
+}
+
+// inheritPacketConn 取出名称或地址匹配的继承UDP socket，没有匹配时返回nil
+func inheritPacketConn(name, address string) (*inheritedSocket, error) {
+	return inheritSocket(name, address, true)
+}
+
+// inheritSocket 取出名称或地址匹配的继承socket
+func inheritSocket(name, address string, packet bool) (*inheritedSocket, error) {
+	inheritOnce.Do(func() {
+		inheritSockets, inheritErr = parseInheritedSockets()
+	})
+	if inheritErr != nil {
+		return nil, inheritErr
+	}
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	for i, s := range inheritSockets {
+		if (s.packetConn != nil) != packet {
+			continue
+		}
+		if (s.name != "" && s.name == name) || sameAddress(s.addr(), address) {
+			inheritSockets = append(inheritSockets[:i], inheritSockets[i+1:]...)
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// sameAddress 监听地址是否和配置的地址一致，配置端口为0时匹配任意端口
+func sameAddress(addr net.Addr, address string) bool {
+	var (
+		addrIP   net.IP
+		addrPort int
+	)
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		addrIP, addrPort = a.IP, a.Port
+	case *net.UDPAddr:
+		addrIP, addrPort = a.IP, a.Port
+	default:
+		return false
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if port != "0" && port != strconv.Itoa(addrPort) {
+		return false
+	}
+	if host == "" {
+		return addrIP.IsUnspecified()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.Equal(addrIP) || (ip.IsUnspecified() && addrIP.IsUnspecified())
+}
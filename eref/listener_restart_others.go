@@ -0,0 +1,19 @@
+//go:build windows
+
+package eref
+
+import (
+	"github.com/gotomicro/ego/core/elog"
+	"net"
+)
+
+// registerRestartListener windows 不支持 SIGUSR2 平滑重启
+func registerRestartListener(_ net.Listener, logger *elog.Component) {
+	logger.Warn("graceful restart is not supported on this platform")
+}
+
+// registerRestartPacketConn windows 不支持 SIGUSR2 平滑重启
+func registerRestartPacketConn(_ net.PacketConn, _ *elog.Component) {}
+
+// notifyParent windows 不支持平滑重启
+func notifyParent(_ *elog.Component) {}
@@ -0,0 +1,135 @@
+//go:build !windows
+
+package eref
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gotomicro/ego/core/elog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// restarter 收到 SIGUSR2 时启动新进程并传递监听socket
+type restarter struct {
+	mu         sync.Mutex
+	once       sync.Once
+	logger     *elog.Component
+	sockets    []restartSocket
+	restarting bool
+}
+
+// restartSocket 需要传递给新进程的socket
+type restartSocket struct {
+	addr net.Addr
+	conn interface{} // net.Listener 或者 net.PacketConn
+}
+
+var defaultRestarter = &restarter{}
+
+// registerRestartListener 注册需要传递给新进程的监听socket，首次调用时开始监听 SIGUSR2
+func registerRestartListener(l net.Listener, logger *elog.Component) {
+	defaultRestarter.register(restartSocket{addr: l.Addr(), conn: l}, logger)
+}
+
+// registerRestartPacketConn 注册需要传递给新进程的UDP socket，例如 HTTP/3
+func registerRestartPacketConn(pc net.PacketConn, logger *elog.Component) {
+	defaultRestarter.register(restartSocket{addr: pc.LocalAddr(), conn: pc}, logger)
+}
+
+// register 注册socket，首次调用时开始监听 SIGUSR2
+func (r *restarter) register(s restartSocket, logger *elog.Component) {
+	r.mu.Lock()
+	r.sockets = append(r.sockets, s)
+	if r.logger == nil {
+		r.logger = logger
+	}
+	r.mu.Unlock()
+	r.once.Do(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGUSR2)
+		go func() {
+			for range sig {
+				if err := r.restart(); err != nil {
+					r.logger.Error("graceful restart fail", elog.FieldErr(err))
+				}
+			}
+		}()
+	})
+}
+
+// restart 用相同的参数和环境变量启动新进程，新进程开始服务后会向当前进程发送 SIGTERM
+func (r *restarter) restart() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restarting {
+		return errors.New("graceful restart is in progress")
+	}
+	files := make([]*os.File, 0, len(r.sockets))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, s := range r.sockets {
+		filer, ok := s.conn.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s can not be inherited", s.addr)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return fmt.Errorf("dup listener %s, %w", s.addr, err)
+		}
+		files = append(files, f)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envInheritFDs+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, fmt.Sprintf("%s=%d", envInheritFDs, len(files)))
+	process, err := os.StartProcess(exe, os.Args, &os.ProcAttr{
+		Env:   env,
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
+	})
+	if err != nil {
+		return fmt.Errorf("start new process, %w", err)
+	}
+	r.restarting = true
+	r.logger.Info("graceful restart, new process started", elog.Int("pid", process.Pid))
+	go func() {
+		state, err := process.Wait()
+		r.mu.Lock()
+		r.restarting = false
+		r.mu.Unlock()
+		// 当前进程还在运行说明新进程没有接管
+		r.logger.Error("graceful restart, new process exited", elog.Int("pid", process.Pid), elog.Any("state", state), elog.FieldErr(err))
+	}()
+	return nil
+}
+
+var notifyParentOnce sync.Once
+
+// notifyParent 新进程开始服务后通知父进程优雅退出
+func notifyParent(logger *elog.Component) {
+	notifyParentOnce.Do(func() {
+		ppid := os.Getppid()
+		if ppid <= 1 {
+			return
+		}
+		if err := syscall.Kill(ppid, syscall.SIGTERM); err != nil {
+			logger.Error("graceful restart, notify parent fail", elog.Int("ppid", ppid), elog.FieldErr(err))
+			return
+		}
+		logger.Info("graceful restart, notify parent to stop", elog.Int("ppid", ppid))
+	})
+}
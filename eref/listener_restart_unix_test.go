@@ -0,0 +1,184 @@
+//go:build !windows
+
+package eref
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/quic-go/quic-go/http3"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 子进程模式的环境变量，值为证书目录
+const envRestartChild = "EREF_TEST_RESTART_CHILD"
+
+// TestGracefulRestartInheritsHTTP3Conn 模拟 SIGUSR2 平滑重启：父进程按 restart 的方式传递TCP、UDP socket，
+// 子进程继承后通过同一个UDP socket提供HTTP/3服务
+func TestGracefulRestartInheritsHTTP3Conn(t *testing.T) {
+	if dir := os.Getenv(envRestartChild); dir != "" {
+		runRestartChild(dir)
+		return
+	}
+	dir := t.TempDir()
+	writeTestCert(t, dir)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	tcpFile, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpFile.Close()
+	udpFile, err := udp.(*net.UDPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udpFile.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGracefulRestartInheritsHTTP3Conn$")
+	cmd.Env = append(os.Environ(),
+		envRestartChild+"="+dir,
+		fmt.Sprintf("%s=%d", envInheritFDs, 2),
+		"EREF_TEST_TCP="+tcp.Addr().String(),
+		"EREF_TEST_UDP="+udp.LocalAddr().String(),
+	)
+	cmd.Dir = dir
+	cmd.ExtraFiles = []*os.File{tcpFile, udpFile}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = stdin.Close()
+		_ = cmd.Wait()
+	}()
+
+	// 子进程输出继承的地址
+	var tcpAddr, udpAddr string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if _, err := fmt.Sscanf(scanner.Text(), "inherited tcp=%s udp=%s", &tcpAddr, &udpAddr); err == nil {
+			break
+		}
+	}
+	go func() { _, _ = io.Copy(io.Discard, stdout) }()
+	if tcpAddr != tcp.Addr().String() || udpAddr != udp.LocalAddr().String() {
+		t.Fatalf("child listen tcp=%q udp=%q, want tcp=%q udp=%q", tcpAddr, udpAddr, tcp.Addr(), udp.LocalAddr())
+	}
+
+	pool := x509.NewCertPool()
+	data, err := os.ReadFile(filepath.Join(dir, "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AppendCertsFromPEM(data)
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer transport.Close()
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	resp, err := client.Get("https://" + udpAddr + "/ping")
+	if err != nil {
+		t.Fatalf("http3 request to inherited conn: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "pong" {
+		t.Fatalf("http3 response %d %q, want 200 \"pong\"", resp.StatusCode, body)
+	}
+}
+
+// runRestartChild 子进程继承socket后提供HTTP/3服务，直到父进程关闭stdin
+func runRestartChild(dir string) {
+	tcp, udp := os.Getenv("EREF_TEST_TCP"), os.Getenv("EREF_TEST_UDP")
+	host, port, _ := net.SplitHostPort(tcp)
+	c := DefaultContainer()
+	c.config.Host = host
+	_, _ = fmt.Sscan(port, &c.config.Port)
+	c.config.EnableTLS = true
+	c.config.TLSCertFile = filepath.Join(dir, "cert.pem")
+	c.config.TLSKeyFile = filepath.Join(dir, "key.pem")
+	c.config.EnableHTTP3 = true
+	c.config.HTTP3Address = udp
+	c.config.EnableGracefulRestart = true
+	comp := c.Build(WithContainer(restful.NewContainer()))
+	ws := NewRoute()
+	ws.Route(ws.GET("/ping").To(RouteContext(func(ctx Context) {
+		_, _ = ctx.Write([]byte("pong"))
+	})))
+	comp.Add(ws)
+	if err := comp.Init(); err != nil {
+		fmt.Println("init error", err)
+		os.Exit(1)
+	}
+	// 不调用 Start，避免向父进程发送 SIGTERM
+	go func() { _ = comp.newHTTP3Server().Serve(comp.http3Conn) }()
+	fmt.Printf("inherited tcp=%s udp=%s\n", comp.listener.Addr(), comp.HTTP3Addr())
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+// writeTestCert 生成 127.0.0.1 的自签名证书
+func writeTestCert(t *testing.T, dir string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "eref test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package eref
+
+import (
+	"context"
+	"github.com/gotomicro/ego/core/elog"
+	"net"
+	"strings"
+)
+
+// listenAll 监听 Address 和 ExtraAddresses，开启 EnableReusePort 时每个地址创建 ReusePortListeners 个listener
+// 失败时关闭已经创建的listener
+func (c *Component) listenAll() ([]net.Listener, error) {
+	copies := 1
+	if c.config.EnableReusePort && c.config.ReusePortListeners > 1 {
+		copies = c.config.ReusePortListeners
+	}
+	addresses := append([]string{c.config.Address()}, c.config.ExtraAddresses...)
+	listeners := make([]net.Listener, 0, len(addresses)*copies)
+	for i, address := range addresses {
+		for j := 0; j < copies; j++ {
+			// systemd 按名称匹配时只对应主地址
+			name := ""
+			if i == 0 && j == 0 {
+				name = c.name
+			}
+			l, err := c.listen(name, address)
+			if err != nil {
+				for _, l := range listeners {
+					_ = l.Close()
+				}
+				return nil, err
+			}
+			listeners = append(listeners, l)
+			// 端口为0时，同一地址的其他listener使用实际分配的端口
+			if strings.HasSuffix(address, ":0") {
+				address = l.Addr().String()
+			}
+		}
+	}
+	return listeners, nil
+}
+
+// listen 开启继承时优先使用 systemd 或者平滑重启的父进程传递的socket
+func (c *Component) listen(name, address string) (net.Listener, error) {
+	if c.config.EnableListenerInherit || c.config.EnableGracefulRestart {
+		s, err := inheritListener(name, address)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			c.inherited = c.inherited || s.parent
+			c.logger.Info("inherit listener", elog.FieldAddr(s.listener.Addr().String()), elog.Any("parent", s.parent))
+			return s.listener, nil
+		}
+	}
+	network := c.config.Network
+	if network == "" {
+		network = "tcp"
+	}
+	lc := net.ListenConfig{}
+	if c.config.EnableReusePort {
+		lc.Control = reusePortControl
+	}
+	return lc.Listen(context.Background(), network, address)
+}
+
+// Listeners 全部的listener，第一个为 Address 对应的listener
+func (c *Component) Listeners() []net.Listener {
+	return append([]net.Listener(nil), c.listeners...)
+}
+
+// listenAddresses 去重后的监听地址
+func (c *Component) listenAddresses() []string {
+	addresses := make([]string, 0, len(c.listeners))
+	for _, l := range c.listeners {
+		if address := l.Addr().String(); !containsString(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
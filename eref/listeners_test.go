@@ -0,0 +1,105 @@
+package eref_test
+
+import (
+	"context"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// pingRoute 返回 pong 的路由
+func pingRoute(s *ereftest.Server) {
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/ping").To(eref.RouteContext(func(ctx eref.Context) {
+		_, _ = ctx.Write([]byte("pong"))
+	})))
+	s.Add(ws)
+}
+
+// expectPong 通过真实连接请求 /api/ping
+func expectPong(t *testing.T, address string) {
+	t.Helper()
+	resp, err := testClient.Get("http://" + address + "/api/ping")
+	if err != nil {
+		t.Fatalf("request %s: %v", address, err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(body) != "pong" {
+		t.Errorf("%s response = %d %q", address, resp.StatusCode, body)
+	}
+}
+
+func TestMultipleListeners(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"Host":           "127.0.0.1",
+		"Port":           0,
+		"ExtraAddresses": []string{"127.0.0.1:0"},
+	})
+	pingRoute(s)
+	startServer(t, s)
+
+	listeners := s.Component.Listeners()
+	if len(listeners) != 2 || s.Component.Listener() != listeners[0] {
+		t.Fatalf("listeners = %v, Listener = %v", listeners, s.Component.Listener())
+	}
+	addresses := []string{listeners[0].Addr().String(), listeners[1].Addr().String()}
+	if addresses[0] == addresses[1] {
+		t.Fatalf("listeners share address %s", addresses[0])
+	}
+	for _, address := range addresses {
+		expectPong(t, address)
+	}
+	info := s.Component.Info()
+	if info.Address != addresses[0] || info.Metadata["addresses"] != strings.Join(addresses, ",") {
+		t.Errorf("info address = %s, metadata = %v", info.Address, info.Metadata)
+	}
+
+	if err := s.Component.GracefulStop(context.Background()); err != nil {
+		t.Fatalf("GracefulStop: %v", err)
+	}
+	// 关闭后全部地址都不再接收连接
+	for _, address := range addresses {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			_ = conn.Close()
+			t.Errorf("%s still accepting connections after GracefulStop", address)
+		}
+	}
+}
+
+func TestListenFailureClosesListeners(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := free.Addr().(*net.TCPAddr).Port
+	_ = free.Close()
+
+	s := loadServer(t, map[string]interface{}{
+		"Host":           "127.0.0.1",
+		"Port":           port,
+		"ExtraAddresses": []string{busy.Addr().String()},
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Init with an address in use did not panic")
+			}
+		}()
+		_ = s.Component.Init()
+	}()
+	// 已经创建的listener被关闭，端口可以重新监听
+	l, err := net.Listen("tcp", free.Addr().String())
+	if err != nil {
+		t.Fatalf("primary address not released: %v", err)
+	}
+	_ = l.Close()
+}
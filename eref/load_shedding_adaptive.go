@@ -0,0 +1,220 @@
+package eref
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// bbrBucketDuration BBR 统计桶大小
+	bbrBucketDuration = 100 * time.Millisecond
+	// bbrBucketNum BBR 统计桶数量，共统计1s
+	bbrBucketNum = 10
+	// bbrCoolDown 触发限流后的冷却时间，冷却时间内即使CPU恢复也继续按最大并发限流
+	bbrCoolDown = time.Second
+	// defaultShedCPUThreshold 默认CPU阈值
+	defaultShedCPUThreshold = 0.8
+)
+
+type bbrBucket struct {
+	id    int64
+	pass  int64
+	rtSum time.Duration
+}
+
+// bbrLimiter 参考 BBR 的自适应限流
+// CPU 超过阈值时，最大并发 = 窗口内最大每桶通过数 * 最小平均耗时 / 桶大小，超过最大并发的请求被拒绝
+type bbrLimiter struct {
+	threshold float64
+	mu        sync.Mutex
+	buckets   [bbrBucketNum]bbrBucket
+	lastDrop  int64
+}
+
+func newBBRLimiter(threshold float64) *bbrLimiter {
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultShedCPUThreshold
+	}
+	startCPUSampler()
+	return &bbrLimiter{threshold: threshold}
+}
+
+// Allow implements adaptiveLimiter
+func (l *bbrLimiter) Allow(inFlight int64, priority int) bool {
+	if priority == PriorityCritical {
+		return true
+	}
+	now := time.Now()
+	overload := cpuUsage() > l.threshold
+	if !overload && now.UnixNano()-atomic.LoadInt64(&l.lastDrop) > int64(bbrCoolDown) {
+		return true
+	}
+	maxInFlight := l.maxInFlight(now)
+	// 没有统计数据时不限流
+	if maxInFlight <= 0 || float64(inFlight) < maxInFlight*adaptivePriorityRatio[priority] {
+		return true
+	}
+	atomic.StoreInt64(&l.lastDrop, now.UnixNano())
+	return false
+}
+
+// Observe implements adaptiveLimiter
+func (l *bbrLimiter) Observe(rt time.Duration) {
+	id := time.Now().UnixNano() / int64(bbrBucketDuration)
+	l.mu.Lock()
+	b := &l.buckets[id%bbrBucketNum]
+	if b.id != id {
+		*b = bbrBucket{id: id}
+	}
+	b.pass++
+	b.rtSum += rt
+	l.mu.Unlock()
+}
+
+// maxInFlight 根据最近1s的统计估算最大并发，不统计当前桶
+func (l *bbrLimiter) maxInFlight(now time.Time) float64 {
+	current := now.UnixNano() / int64(bbrBucketDuration)
+	var maxPass int64
+	minRT := time.Duration(math.MaxInt64)
+	l.mu.Lock()
+	for _, b := range l.buckets {
+		if b.id == current || current-b.id >= bbrBucketNum || b.pass == 0 {
+			continue
+		}
+		if b.pass > maxPass {
+			maxPass = b.pass
+		}
+		if rt := b.rtSum / time.Duration(b.pass); rt < minRT {
+			minRT = rt
+		}
+	}
+	l.mu.Unlock()
+	if maxPass == 0 {
+		return 0
+	}
+	return float64(maxPass) * float64(minRT) / float64(bbrBucketDuration)
+}
+
+const (
+	// gradientSampleWindow Gradient 采样窗口
+	gradientSampleWindow = 100 * time.Millisecond
+	// gradientLongWindow 长期耗时的平滑系数，约等于最近600个采样窗口
+	gradientLongWindow = 600
+	// gradientSmoothing 并发上限的平滑系数
+	gradientSmoothing = 0.2
+	// defaultShedLatencyTolerance 默认耗时容忍度
+	defaultShedLatencyTolerance = 2
+	// defaultGradientInitialLimit 默认初始并发上限
+	defaultGradientInitialLimit = 100
+	// defaultGradientMaxLimit 默认最大并发上限
+	defaultGradientMaxLimit = 1000
+)
+
+// gradientLimiter 参考 Gradient2 的自适应限流
+// 比较短期耗时和长期耗时，耗时变长时按比例缩小并发上限，耗时恢复后逐步放大
+type gradientLimiter struct {
+	tolerance   float64
+	maxLimit    float64
+	mu          sync.Mutex
+	limit       float64
+	longRTT     float64
+	windowStart time.Time
+	windowSum   time.Duration
+	windowCount int64
+}
+
+func newGradientLimiter(tolerance float64, maxConcurrency int) *gradientLimiter {
+	if tolerance < 1 {
+		tolerance = defaultShedLatencyTolerance
+	}
+	l := &gradientLimiter{
+		tolerance:   tolerance,
+		limit:       defaultGradientInitialLimit,
+		maxLimit:    defaultGradientMaxLimit,
+		windowStart: time.Now(),
+	}
+	if maxConcurrency > 0 {
+		l.maxLimit = float64(maxConcurrency)
+		l.limit = math.Min(l.limit, l.maxLimit)
+	}
+	return l
+}
+
+// Allow implements adaptiveLimiter
+func (l *gradientLimiter) Allow(inFlight int64, priority int) bool {
+	if priority == PriorityCritical {
+		return true
+	}
+	l.mu.Lock()
+	limit := l.limit
+	l.mu.Unlock()
+	return float64(inFlight) < limit*adaptivePriorityRatio[priority]
+}
+
+// Observe implements adaptiveLimiter
+func (l *gradientLimiter) Observe(rt time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.windowSum += rt
+	l.windowCount++
+	if now.Sub(l.windowStart) < gradientSampleWindow {
+		return
+	}
+	shortRTT := float64(l.windowSum) / float64(l.windowCount)
+	l.windowStart, l.windowSum, l.windowCount = now, 0, 0
+	if shortRTT <= 0 {
+		return
+	}
+	if l.longRTT == 0 {
+		l.longRTT = shortRTT
+	} else {
+		l.longRTT += (shortRTT - l.longRTT) * 2 / (gradientLongWindow + 1)
+	}
+	// 长期耗时明显偏高时加速回落，避免一次抖动长期压低并发
+	if l.longRTT/shortRTT > 2 {
+		l.longRTT *= 0.95
+	}
+	gradient := math.Max(0.5, math.Min(1, l.tolerance*l.longRTT/shortRTT))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-gradientSmoothing) + newLimit*gradientSmoothing
+	l.limit = math.Max(1, math.Min(l.maxLimit, l.limit))
+}
+
+var (
+	cpuUsageBits   uint64
+	cpuSamplerOnce sync.Once
+)
+
+// cpuUsage 最近一次采样的CPU使用率，取值0~1
+func cpuUsage() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&cpuUsageBits))
+}
+
+// startCPUSampler 启动CPU采样，不支持的平台上使用率始终为0
+func startCPUSampler() {
+	cpuSamplerOnce.Do(func() {
+		idle, total, err := readCPUStat()
+		if err != nil {
+			return
+		}
+		go func() {
+			ticker := time.NewTicker(250 * time.Millisecond)
+			defer ticker.Stop()
+			var usage float64
+			for range ticker.C {
+				curIdle, curTotal, err := readCPUStat()
+				if err != nil || curTotal <= total {
+					continue
+				}
+				sample := 1 - float64(curIdle-idle)/float64(curTotal-total)
+				idle, total = curIdle, curTotal
+				// 指数平滑，避免瞬时抖动
+				usage = usage*0.8 + sample*0.2
+				atomic.StoreUint64(&cpuUsageBits, math.Float64bits(usage))
+			}
+		}()
+	})
+}
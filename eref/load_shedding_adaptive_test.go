@@ -0,0 +1,94 @@
+package eref
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	for value, want := range map[string]int{
+		"":         PriorityNormal,
+		"Critical": PriorityCritical,
+		" high ":   PriorityHigh,
+		"low":      PriorityLow,
+		"0":        PriorityCritical,
+		"3":        PriorityLow,
+		"4":        PriorityNormal,
+		"urgent":   PriorityNormal,
+		"-1":       PriorityNormal,
+		"normal":   PriorityNormal,
+	} {
+		if got := parsePriority(value); got != want {
+			t.Errorf("parsePriority(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := newGradientLimiter(0, 50)
+	if l.limit != 50 || l.tolerance != defaultShedLatencyTolerance {
+		t.Fatalf("limit = %v, tolerance = %v", l.limit, l.tolerance)
+	}
+	observe := func(rt time.Duration) {
+		l.windowStart = time.Now().Add(-gradientSampleWindow)
+		l.Observe(rt)
+	}
+	for i := 0; i < 20; i++ {
+		observe(10 * time.Millisecond)
+	}
+	if l.limit != 50 {
+		t.Errorf("stable latency limit = %v, want max 50", l.limit)
+	}
+	// 耗时远超容忍度时缩小并发上限
+	for i := 0; i < 20; i++ {
+		observe(time.Second)
+	}
+	shrunk := l.limit
+	if shrunk >= 50 {
+		t.Fatalf("slow latency limit = %v, want < 50", shrunk)
+	}
+	if l.Allow(int64(math.Ceil(shrunk)), PriorityHigh) {
+		t.Error("request over limit allowed")
+	}
+	if !l.Allow(int64(math.Ceil(shrunk)), PriorityCritical) {
+		t.Error("critical request rejected")
+	}
+	if l.Allow(int64(math.Ceil(shrunk*adaptivePriorityRatio[PriorityLow])), PriorityLow) {
+		t.Error("low priority request allowed over its ratio")
+	}
+	// 耗时恢复后逐步放大
+	for i := 0; i < 200; i++ {
+		observe(10 * time.Millisecond)
+	}
+	if l.limit <= shrunk {
+		t.Errorf("recovered limit = %v, want > %v", l.limit, shrunk)
+	}
+}
+
+func TestBBRLimiter(t *testing.T) {
+	defer atomic.StoreUint64(&cpuUsageBits, math.Float64bits(cpuUsage()))
+	l := &bbrLimiter{threshold: 0.5}
+	now := time.Now()
+	// 上一个桶通过20个请求，平均耗时50ms，估算最大并发 20 * 50ms / 100ms = 10
+	id := now.UnixNano()/int64(bbrBucketDuration) - 1
+	l.buckets[id%bbrBucketNum] = bbrBucket{id: id, pass: 20, rtSum: 20 * 50 * time.Millisecond}
+
+	atomic.StoreUint64(&cpuUsageBits, math.Float64bits(0.2))
+	if !l.Allow(100, PriorityNormal) {
+		t.Error("request rejected without cpu overload")
+	}
+	atomic.StoreUint64(&cpuUsageBits, math.Float64bits(0.9))
+	if !l.Allow(5, PriorityNormal) || l.Allow(10, PriorityNormal) {
+		t.Error("cpu overload: want max in-flight 10 * 0.9")
+	}
+	if !l.Allow(100, PriorityCritical) {
+		t.Error("critical request rejected")
+	}
+	// 冷却时间内即使CPU恢复也继续限流
+	atomic.StoreUint64(&cpuUsageBits, math.Float64bits(0.2))
+	if l.Allow(10, PriorityNormal) {
+		t.Error("request allowed during cool down")
+	}
+}
@@ -0,0 +1,55 @@
+package eref
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore 签名请求的nonce存储，用于防重放，多实例部署时可以基于redis等实现
+type NonceStore interface {
+	// Use 记录nonce，ttl内已经使用过时返回false
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// memoryNonceStore 内存nonce存储，只在单实例内生效
+type memoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore 新建内存nonce存储
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Use implements NonceStore
+func (s *memoryNonceStore) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	if expireAt, ok := s.nonces[nonce]; ok && now.Before(expireAt) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// sweep 定期清理过期的nonce，避免内存无限增长
+func (s *memoryNonceStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for nonce, expireAt := range s.nonces {
+		if !now.Before(expireAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package eref
+
+import (
+	"encoding/json"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gotomicro/ego/core/eapp"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openAPIVersion 生成的文档版本
+const openAPIVersion = "3.0.3"
+
+// defaultOpenAPIPath 默认的文档路径
+const defaultOpenAPIPath = "/openapi.json"
+
+// 文档中的鉴权方式名称
+const (
+	securityBearer    = "bearerAuth"
+	securityAPIKey    = "apiKeyAuth"
+	securitySignature = "signatureAuth"
+	securityMTLS      = "mutualTLS"
+)
+
+// OpenAPI OpenAPI 3 文档
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo 文档信息
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIComponents 复用的schema和鉴权方式
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme 鉴权方式
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// OpenAPIOperation 路由
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Roles       []string                    `json:"x-roles,omitempty"`
+	Scopes      []string                    `json:"x-scopes,omitempty"`
+	Policy      string                      `json:"x-policy,omitempty"`
+}
+
+// OpenAPIParameter 路由参数
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody 请求体
+type OpenAPIRequestBody struct {
+	Description string                       `json:"description,omitempty"`
+	Required    bool                         `json:"required,omitempty"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse 响应
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType 请求、响应的内容
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema,omitempty"`
+}
+
+// mimeForm 表单参数默认的请求体类型
+const mimeForm = "application/x-www-form-urlencoded"
+
+// pathParamRegexp 路由模板参数，{id}、{id:[0-9]+}、{path:*}
+var pathParamRegexp = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPI 根据已注册的路由生成 OpenAPI 3 文档
+func (c *Component) OpenAPI() *OpenAPI {
+	builder := newSchemaBuilder()
+	doc := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       c.config.OpenAPITitle,
+			Description: c.config.OpenAPIDescription,
+			Version:     c.config.OpenAPIVersion,
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = eapp.Name()
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = eapp.AppVersion()
+	}
+	// version 是必填字段
+	if doc.Info.Version == "" {
+		doc.Info.Version = "0.0.0"
+	}
+	security := c.openAPISecurity()
+	if len(security.schemes) > 0 {
+		doc.Components.SecuritySchemes = security.schemes
+	}
+	for _, ws := range c.container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			// 文档、路由信息等内置接口不写入文档
+			if builtin, _ := route.Metadata[metadataBuiltin].(bool); builtin {
+				continue
+			}
+			path := pathParamRegexp.ReplaceAllString(route.Path, "{$1}")
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = c.openAPIOperation(builder, route, security)
+		}
+	}
+	if len(builder.schemas) > 0 {
+		doc.Components.Schemas = builder.schemas
+	}
+	return doc
+}
+
+// ExportOpenAPI 将文档写入文件，不需要启动服务，可以在 ego.Job 等命令中注册路由后调用
+// 生成文档后提交到仓库或者发布到文档平台
+func (c *Component) ExportOpenAPI(file string) error {
+	data, err := json.MarshalIndent(c.OpenAPI(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// openAPIHandler 文档接口
+func (c *Component) openAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	data, err := json.Marshal(c.OpenAPI())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", restful.MIME_JSON)
+	_, _ = w.Write(data)
+}
+
+// openAPISecurityConfig 开启的鉴权方式以及各自的排除路由
+type openAPISecurityConfig struct {
+	schemes  map[string]*OpenAPISecurityScheme
+	excludes map[string]routeMatcher
+	anyOf    bool // AuthMode 为 any，JWT、API key、签名任意一个即可
+}
+
+// openAPISecurity 根据开启的鉴权中间件生成鉴权方式
+func (c *Component) openAPISecurity() openAPISecurityConfig {
+	s := openAPISecurityConfig{
+		schemes:  make(map[string]*OpenAPISecurityScheme),
+		excludes: make(map[string]routeMatcher),
+		anyOf:    c.config.AuthMode == AuthModeAny,
+	}
+	if c.config.EnableJWT {
+		s.schemes[securityBearer] = &OpenAPISecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+		s.excludes[securityBearer], _ = newRouteMatcher("JWTExcludeRoutes", c.config.JWTExcludeRoutes)
+	}
+	if c.config.EnableAPIKey {
+		scheme := &OpenAPISecurityScheme{Type: "apiKey", In: "header", Name: HeaderXAPIKey}
+		if sources, err := parseCredentialLookup("APIKeyLookup", c.config.APIKeyLookup); err == nil && c.config.APIKeyLookup != "" {
+			scheme.In, scheme.Name = sources[0].from, sources[0].name
+		}
+		s.schemes[securityAPIKey] = scheme
+		s.excludes[securityAPIKey], _ = newRouteMatcher("APIKeyExcludeRoutes", c.config.APIKeyExcludeRoutes)
+	}
+	if c.config.EnableSignature {
+		s.schemes[securitySignature] = &OpenAPISecurityScheme{
+			Type:        "apiKey",
+			In:          "header",
+			Name:        HeaderXSignature,
+			Description: "HMAC-SHA256 signature with " + HeaderXAccessKey + ", " + HeaderXTimestamp + ", " + HeaderXNonce + " headers",
+		}
+		s.excludes[securitySignature], _ = newRouteMatcher("SignatureExcludeRoutes", c.config.SignatureExcludeRoutes)
+	}
+	if c.config.EnableMTLS && c.config.EnableTLS {
+		s.schemes[securityMTLS] = &OpenAPISecurityScheme{Type: "mutualTLS"}
+		s.excludes[securityMTLS], _ = newRouteMatcher("MTLSExcludeRoutes", c.config.MTLSExcludeRoutes)
+	}
+	return s
+}
+
+// openAPIOperation 生成单个路由的文档
+func (c *Component) openAPIOperation(builder *schemaBuilder, route restful.Route, security openAPISecurityConfig) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: route.Operation,
+		Summary:     route.Doc,
+		Description: route.Notes,
+		Deprecated:  route.Deprecated,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+	// 路由注释优先作为摘要
+	if comment, ok := c.routes.comment(route.Method, route.Path); ok && comment != "" {
+		op.Summary = comment
+		if op.Description == "" && route.Doc != comment {
+			op.Description = route.Doc
+		}
+	}
+
+	documented := make(map[string]bool)
+	var form *OpenAPISchema
+	for _, param := range route.ParameterDocs {
+		data := param.Data()
+		schema := dataTypeSchema(data.DataType, data.DataFormat)
+		schema.Default = data.DefaultValue
+		schema.Pattern = data.Pattern
+		schema.Minimum, schema.Maximum = data.Minimum, data.Maximum
+		for value := range data.AllowableValues {
+			schema.Enum = append(schema.Enum, value)
+		}
+		sort.Strings(schema.Enum)
+		if data.AllowMultiple && schema.Type != "array" {
+			schema = &OpenAPISchema{Type: "array", Items: schema}
+		}
+		switch data.Kind {
+		case restful.PathParameterKind, restful.QueryParameterKind, restful.HeaderParameterKind:
+			in := map[int]string{
+				restful.PathParameterKind:   "path",
+				restful.QueryParameterKind:  "query",
+				restful.HeaderParameterKind: "header",
+			}[data.Kind]
+			documented[in+":"+data.Name] = true
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{
+				Name:        data.Name,
+				In:          in,
+				Description: data.Description,
+				Required:    data.Required || in == "path",
+				Schema:      schema,
+			})
+		case restful.FormParameterKind:
+			if form == nil {
+				form = &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+			}
+			schema.Description = data.Description
+			form.Properties[data.Name] = schema
+			if data.Required {
+				form.Required = append(form.Required, data.Name)
+			}
+		case restful.BodyParameterKind:
+			body := builder.schemaOf(route.ReadSample)
+			if body == nil {
+				body = schema
+			}
+			op.RequestBody = &OpenAPIRequestBody{
+				Description: data.Description,
+				Required:    data.Required,
+				Content:     openAPIContent(bodyMimeTypes(route.Consumes), body),
+			}
+		}
+	}
+	// 路由模板中没有声明的路径参数
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(route.Path, -1) {
+		if !documented["path:"+match[1]] {
+			documented["path:"+match[1]] = true
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: match[1], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+		}
+	}
+	if op.RequestBody == nil && form != nil {
+		op.RequestBody = &OpenAPIRequestBody{Content: openAPIContent(formMimeTypes(route.Consumes), form)}
+	}
+	if op.RequestBody == nil && route.ReadSample != nil {
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: openAPIContent(bodyMimeTypes(route.Consumes), builder.schemaOf(route.ReadSample))}
+	}
+
+	for code, res := range route.ResponseErrors {
+		op.Responses[strconv.Itoa(code)] = openAPIResponse(builder, res, route.Produces)
+	}
+	if route.DefaultResponse != nil {
+		op.Responses["default"] = openAPIResponse(builder, *route.DefaultResponse, route.Produces)
+	}
+	if route.WriteSample != nil {
+		if _, ok := op.Responses["200"]; !ok {
+			op.Responses["200"] = &OpenAPIResponse{
+				Description: http.StatusText(http.StatusOK),
+				Content:     openAPIContent(route.Produces, builder.schemaOf(route.WriteSample)),
+			}
+		}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+
+	requirement, public := routeRequirement(route.Metadata)
+	op.Roles, op.Scopes, op.Policy = requirement.Roles, requirement.Scopes, requirement.Policy
+	if !public {
+		var (
+			required = make(map[string][]string)
+			anyOf    []string
+		)
+		for name := range security.schemes {
+			if security.excludes[name].Match(route.Method, route.Path) {
+				continue
+			}
+			// 排除了该路由的鉴权方式不参与，其余鉴权方式任意一个通过即可
+			if security.anyOf && name != securityMTLS {
+				anyOf = append(anyOf, name)
+			} else {
+				required[name] = []string{}
+			}
+		}
+		if len(anyOf) > 0 {
+			// 多个 Security Requirement 之间为或的关系
+			sort.Strings(anyOf)
+			for _, name := range anyOf {
+				alternative := map[string][]string{name: {}}
+				for k, v := range required {
+					alternative[k] = v
+				}
+				op.Security = append(op.Security, alternative)
+			}
+		} else if len(required) > 0 {
+			op.Security = []map[string][]string{required}
+		}
+	}
+	return op
+}
+
+func openAPIResponse(builder *schemaBuilder, res restful.ResponseError, produces []string) *OpenAPIResponse {
+	r := &OpenAPIResponse{Description: res.Message}
+	if r.Description == "" {
+		r.Description = http.StatusText(res.Code)
+	}
+	if schema := builder.schemaOf(res.Model); schema != nil {
+		r.Content = openAPIContent(produces, schema)
+	}
+	return r
+}
+
+// bodyMimeTypes 请求体的类型，未声明时默认json
+func bodyMimeTypes(consumes []string) []string {
+	if len(consumes) == 0 {
+		return []string{restful.MIME_JSON}
+	}
+	return consumes
+}
+
+// formMimeTypes 表单的请求体类型，只保留声明的表单类型
+func formMimeTypes(consumes []string) []string {
+	var list []string
+	for _, mime := range consumes {
+		if mime == mimeForm || mime == "multipart/form-data" {
+			list = append(list, mime)
+		}
+	}
+	if len(list) == 0 {
+		return []string{mimeForm}
+	}
+	return list
+}
+
+func openAPIContent(mimeTypes []string, schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+	if len(mimeTypes) == 0 {
+		mimeTypes = []string{restful.MIME_JSON}
+	}
+	content := make(map[string]*OpenAPIMediaType, len(mimeTypes))
+	for _, mime := range mimeTypes {
+		content[mime] = &OpenAPIMediaType{Schema: schema}
+	}
+	return content
+}
@@ -0,0 +1,222 @@
+package eref
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPISchema OpenAPI 3 schema
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Default              string                    `json:"default,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+}
+
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	durationType       = reflect.TypeOf(time.Duration(0))
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonRawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaBuilder 通过反射生成schema，结构体放到 components.schemas 中复用
+type schemaBuilder struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]*OpenAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf 返回示例值对应的schema，nil 返回nil
+func (b *schemaBuilder) schemaOf(sample interface{}) *OpenAPISchema {
+	if sample == nil {
+		return nil
+	}
+	return b.schema(reflect.TypeOf(sample))
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &OpenAPISchema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case jsonRawMessageType:
+		return &OpenAPISchema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		// 自定义序列化的类型无法推断结构
+		if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+			return &OpenAPISchema{}
+		}
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.ref(t)
+	}
+	// interface{}、func、chan 等
+	return &OpenAPISchema{}
+}
+
+// ref 注册结构体并返回引用
+func (b *schemaBuilder) ref(t reflect.Type) *OpenAPISchema {
+	name, ok := b.names[t]
+	if !ok {
+		name = b.uniqueName(t)
+		b.names[t] = name
+		// 先占位，避免递归结构死循环
+		b.schemas[name] = &OpenAPISchema{}
+		*b.schemas[name] = *b.object(t)
+	}
+	return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// uniqueName 结构体名称，重名时带上包名
+func (b *schemaBuilder) uniqueName(t reflect.Type) string {
+	name := t.Name()
+	if _, ok := b.schemas[name]; !ok {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	base := pkg + "." + name
+	name = base
+	for i := 2; ; i++ {
+		if _, ok := b.schemas[name]; !ok {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+// object 结构体字段，字段名使用json tag，匿名字段展开
+func (b *schemaBuilder) object(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	b.fields(t, s)
+	return s
+}
+
+func (b *schemaBuilder) fields(t reflect.Type, s *OpenAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fields(ft, s)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := b.schema(field.Type)
+		if strings.Contains(opts, "string") && prop.Ref == "" {
+			prop = &OpenAPISchema{Type: "string", Format: prop.Format}
+		}
+		// $ref 不能有兄弟字段，引用类型的字段不写描述
+		if desc := field.Tag.Get("description"); desc != "" && prop.Ref == "" {
+			prop.Description = desc
+		}
+		s.Properties[name] = prop
+		if fieldRequired(field) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// fieldRequired binding、validate tag 中声明了required
+func fieldRequired(field reflect.StructField) bool {
+	for _, key := range []string{"binding", "validate"} {
+		for _, rule := range strings.Split(field.Tag.Get(key), ",") {
+			if rule == "required" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dataTypeSchema go-restful 参数的 DataType 对应的schema
+func dataTypeSchema(dataType, dataFormat string) *OpenAPISchema {
+	s := &OpenAPISchema{Format: dataFormat}
+	switch strings.ToLower(dataType) {
+	case "integer", "int", "int64", "uint", "uint64":
+		s.Type = "integer"
+		if s.Format == "" {
+			s.Format = "int64"
+		}
+	case "int32", "int16", "int8", "uint32", "uint16", "uint8":
+		s.Type = "integer"
+		if s.Format == "" {
+			s.Format = "int32"
+		}
+	case "number", "float", "float64", "double", "float32":
+		s.Type = "number"
+	case "boolean", "bool":
+		s.Type = "boolean"
+	case "file":
+		s.Type, s.Format = "string", "binary"
+	case "array", "[]string":
+		s.Type, s.Items = "array", &OpenAPISchema{Type: "string"}
+	default:
+		s.Type = "string"
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+package eref_test
+
+import (
+	"encoding/json"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type openAPIUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// userRoutes 带文档的用户路由
+func userRoutes(s *ereftest.Server) {
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/users/{id:[0-9]+}").To(eref.RouteContext(func(ctx eref.Context) {})).
+		Operation("getUser").
+		Doc("get user").
+		Param(ws.QueryParameter("fields", "returned fields")).
+		Writes(openAPIUser{}))
+	ws.Route(ws.POST("/users").To(eref.RouteContext(func(ctx eref.Context) {})).
+		Reads(openAPIUser{}).
+		Returns(http.StatusConflict, "user exists", nil))
+	s.Add(ws)
+	s.Component.RegisterRouteComment(http.MethodPost, "/api/users", "create user")
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableOpenAPI":        true,
+		"EnableRoutesEndpoint": true,
+		"OpenAPITitle":         "users",
+		"OpenAPIVersion":       "1.0.0",
+	})
+	userRoutes(s)
+
+	var doc eref.OpenAPI
+	s.GET("/openapi.json").Do().ExpectStatus(http.StatusOK).DecodeJSON(&doc)
+	if doc.Info.Title != "users" || doc.Info.Version != "1.0.0" {
+		t.Errorf("info = %+v", doc.Info)
+	}
+	get := doc.Paths["/api/users/{id}"]["get"]
+	if get == nil || get.OperationID != "getUser" || get.Summary != "get user" || len(get.Parameters) != 2 {
+		t.Fatalf("get operation = %+v", get)
+	}
+	if get.Responses["200"] == nil || get.Responses["200"].Content[restful.MIME_JSON] == nil {
+		t.Errorf("get responses = %+v", get.Responses)
+	}
+	post := doc.Paths["/api/users"]["post"]
+	if post == nil || post.Summary != "create user" || post.RequestBody == nil || post.Responses["409"] == nil {
+		t.Fatalf("post operation = %+v", post)
+	}
+	// 内置接口不写入文档
+	for _, path := range []string{"/openapi.json", "/routes", "/healthz"} {
+		if _, ok := doc.Paths[path]; ok {
+			t.Errorf("builtin path %s in document", path)
+		}
+	}
+}
+
+func TestOpenAPIBehindAuth(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableOpenAPI": true,
+		"EnableJWT":     true,
+		"JWTSecret":     testJWTSecret,
+	})
+	userRoutes(s)
+
+	// 文档接口经过鉴权中间件
+	s.GET("/openapi.json").Do().ExpectStatus(http.StatusUnauthorized)
+	var doc eref.OpenAPI
+	s.GET("/openapi.json").Header("Authorization", "Bearer "+signJWT(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()})).Do().
+		ExpectStatus(http.StatusOK).
+		DecodeJSON(&doc)
+	if security := doc.Paths["/api/users"]["post"].Security; len(security) != 1 || security[0]["bearerAuth"] == nil {
+		t.Errorf("security = %v, want bearerAuth", security)
+	}
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	s := ereftest.New(t)
+	userRoutes(s)
+
+	file := filepath.Join(t.TempDir(), "openapi.json")
+	if err := s.Component.ExportOpenAPI(file); err != nil {
+		t.Fatalf("ExportOpenAPI: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read exported file: %v", err)
+	}
+	var doc eref.OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode exported document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths["/api/users/{id}"]["get"] == nil {
+		t.Errorf("exported document = %s", data)
+	}
+}
@@ -1,6 +1,9 @@
 package eref
 
-import "time"
+import (
+	"github.com/emicklei/go-restful/v3"
+	"time"
+)
 
 // Option 可选项
 type Option func(c *Container)
@@ -56,3 +59,54 @@ func WithContextTimeout(timeout time.Duration) Option {
 		c.config.ContextTimeout = timeout
 	}
 }
+
+// WithRateLimitStore 设置限流存储，默认使用内存存储
+func WithRateLimitStore(store RateLimitStore) Option {
+	return func(c *Container) {
+		c.config.rateLimitStore = store
+	}
+}
+
+// WithRateLimitKeyFunc 设置自定义限流维度
+func WithRateLimitKeyFunc(fn RateLimitKeyFunc) Option {
+	return func(c *Container) {
+		c.config.rateLimitKeyFunc = fn
+	}
+}
+
+// WithAPIKeyProvider 设置API key来源，默认使用配置中的 APIKeys
+func WithAPIKeyProvider(provider APIKeyProvider) Option {
+	return func(c *Container) {
+		c.config.apiKeyProvider = provider
+	}
+}
+
+// WithNonceStore 设置签名鉴权的nonce存储，默认使用内存存储
+func WithNonceStore(store NonceStore) Option {
+	return func(c *Container) {
+		c.config.nonceStore = store
+	}
+}
+
+// WithContainer 使用独立的 restful 容器，默认为 restful.DefaultContainer
+// 多个组件或者测试之间的路由、中间件互不影响
+func WithContainer(container *restful.Container) Option {
+	return func(c *Container) {
+		c.config.container = container
+	}
+}
+
+// WithIsolated 构建独立的组件，不监听配置变更，也不作为 BuildWebsocket 使用的组件
+// 用于 ereftest 等同一进程中构建多个临时组件的场景，组件关闭后可以被回收
+func WithIsolated() Option {
+	return func(c *Container) {
+		c.config.isolated = true
+	}
+}
+
+// WithPolicyEngine 设置授权策略，默认使用内置的RBAC策略
+func WithPolicyEngine(engine PolicyEngine) Option {
+	return func(c *Container) {
+		c.config.policyEngine = engine
+	}
+}
@@ -0,0 +1,152 @@
+package eref
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitTokenBucket 令牌桶算法
+	RateLimitTokenBucket = "token_bucket"
+	// RateLimitSlidingWindow 滑动窗口算法
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimit 限流规则
+type RateLimit struct {
+	Algorithm string        // 限流算法，token_bucket 或 sliding_window
+	Rate      int           // 每个窗口允许的请求数
+	Window    time.Duration // 窗口大小
+	Burst     int           // 令牌桶容量，默认等于 Rate
+}
+
+// RateLimitResult 限流结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 窗口内允许的请求数
+	Remaining  int           // 窗口内剩余的请求数
+	Reset      time.Duration // 多久之后配额完全恢复
+	RetryAfter time.Duration // 被限流时，多久之后可以重试
+}
+
+// RateLimitStore 限流存储，内置内存实现，多实例共享限流时可以基于redis等实现
+type RateLimitStore interface {
+	// Allow 消耗key的一次配额并返回限流结果
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// memoryRateLimitStore 内存限流存储，只在单实例内生效
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// rateLimitBucket 单个key的限流状态
+type rateLimitBucket struct {
+	// 令牌桶
+	tokens float64
+	last   time.Time
+	// 滑动窗口
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+	// 过期时间，超过后可以清理
+	expireAt time.Time
+}
+
+// NewMemoryRateLimitStore 新建内存限流存储
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: make(map[string]*rateLimitBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements RateLimitStore
+func (s *memoryRateLimitStore) Allow(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{}
+		s.buckets[key] = b
+	}
+	b.expireAt = now.Add(2 * limit.Window)
+	if limit.Algorithm == RateLimitSlidingWindow {
+		return b.slidingWindow(now, limit), nil
+	}
+	return b.tokenBucket(now, limit), nil
+}
+
+// sweep 定期清理过期的key，避免内存无限增长
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.After(b.expireAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (b *rateLimitBucket) tokenBucket(now time.Time, limit RateLimit) RateLimitResult {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+	// 每秒补充的令牌数
+	perSecond := float64(limit.Rate) / limit.Window.Seconds()
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	}
+	b.last = now
+	res := RateLimitResult{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((float64(burst) - b.tokens) / perSecond * float64(time.Second))
+	return res
+}
+
+// slidingWindow 滑动窗口计数，用上一个窗口的计数按时间加权估算
+func (b *rateLimitBucket) slidingWindow(now time.Time, limit RateLimit) RateLimitResult {
+	start := now.Truncate(limit.Window)
+	switch {
+	case b.windowStart.Equal(start):
+	case b.windowStart.Add(limit.Window).Equal(start):
+		b.prevCount, b.currCount = b.currCount, 0
+	default:
+		b.prevCount, b.currCount = 0, 0
+	}
+	b.windowStart = start
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+	count := int(math.Floor(float64(b.prevCount)*weight)) + b.currCount
+	res := RateLimitResult{Limit: limit.Rate, Reset: limit.Window - elapsed}
+	if count < limit.Rate {
+		b.currCount++
+		count++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = limit.Window - elapsed
+	}
+	res.Remaining = limit.Rate - count
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res
+}
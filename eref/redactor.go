@@ -0,0 +1,229 @@
+package eref
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultRedactMask 默认脱敏掩码
+const defaultRedactMask = "******"
+
+// defaultRedactHeaders 始终打码的header
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", HeaderXAPIKey, HeaderXSignature}
+
+// redactor 访问日志脱敏器，对header、JSON字段以及正则匹配的内容打码
+type redactor struct {
+	mask     string
+	headers  map[string]struct{} // 需要打码的header，key为CanonicalHeaderKey
+	queries  map[string]struct{} // 需要打码的查询参数，开启的鉴权方式通过 query 读取凭证时添加
+	fields   [][]string          // JSON字段路径，按 . 切分
+	patterns []*regexp.Regexp    // 需要打码的正则
+}
+
+// newRedactor 根据配置构建脱敏器
+func newRedactor(config *Config) (*redactor, error) {
+	r := &redactor{
+		mask:    config.AccessInterceptorRedactMask,
+		headers: make(map[string]struct{}),
+		queries: make(map[string]struct{}),
+	}
+	if r.mask == "" {
+		r.mask = defaultRedactMask
+	}
+	for _, name := range append(defaultRedactHeaders, config.AccessInterceptorRedactHeaders...) {
+		r.headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = struct{}{}
+	}
+	// 鉴权读取凭证的header、查询参数，cookie 已经整体打码
+	sources, err := credentialSources(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		switch source.from {
+		case "header":
+			r.headers[http.CanonicalHeaderKey(source.name)] = struct{}{}
+		case "query":
+			r.queries[source.name] = struct{}{}
+		}
+	}
+	for _, field := range config.AccessInterceptorRedactFields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		r.fields = append(r.fields, strings.Split(field, "."))
+	}
+	for _, pattern := range config.AccessInterceptorRedactPatterns {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AccessInterceptorRedactPatterns %q, %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, reg)
+	}
+	return r, nil
+}
+
+// credentialSources 开启的鉴权方式自定义的凭证读取位置，默认位置的header已经在 defaultRedactHeaders 中
+func credentialSources(config *Config) ([]credentialSource, error) {
+	var sources []credentialSource
+	if config.EnableJWT && config.JWTTokenLookup != "" {
+		jwtSources, err := parseCredentialLookup("JWTTokenLookup", config.JWTTokenLookup)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, jwtSources...)
+	}
+	if config.EnableAPIKey && config.APIKeyLookup != "" {
+		apiKeySources, err := parseCredentialLookup("APIKeyLookup", config.APIKeyLookup)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, apiKeySources...)
+	}
+	return sources, nil
+}
+
+// URI 返回凭证查询参数打码后的 RequestURI，保持参数顺序
+func (r *redactor) URI(u *url.URL) string {
+	if r == nil || len(r.queries) == 0 || u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			if _, ok := r.queries[name]; ok {
+				params[i] = key + "=" + r.mask
+			}
+		}
+	}
+	masked := *u
+	masked.RawQuery = strings.Join(params, "&")
+	return masked.RequestURI()
+}
+
+// Header 返回打码后的header副本，不修改原header
+func (r *redactor) Header(h http.Header) http.Header {
+	if r == nil || len(h) == 0 {
+		return h
+	}
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		if _, ok := r.headers[http.CanonicalHeaderKey(k)]; ok {
+			masked := make([]string, len(vs))
+			for i := range vs {
+				masked[i] = r.mask
+			}
+			out[k] = masked
+			continue
+		}
+		out[k] = r.replacePatterns(vs)
+	}
+	return out
+}
+
+// Payload 返回打码后的报文
+// 如果报文是JSON，先按字段路径打码，再对整个报文做正则替换
+func (r *redactor) Payload(b []byte) string {
+	if r == nil || len(b) == 0 {
+		return string(b)
+	}
+	if len(r.fields) > 0 {
+		b = r.redactFields(b)
+	}
+	s := string(b)
+	for _, reg := range r.patterns {
+		s = reg.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+func (r *redactor) replacePatterns(vs []string) []string {
+	if len(r.patterns) == 0 {
+		return vs
+	}
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		for _, reg := range r.patterns {
+			v = reg.ReplaceAllString(v, r.mask)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// redactFields 按字段路径对JSON报文打码，非JSON报文原样返回
+func (r *redactor) redactFields(b []byte) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return b
+	}
+	for _, path := range r.fields {
+		// 单个字段名在任意层级匹配，多级路径从根节点开始匹配
+		if len(path) == 1 {
+			r.maskKeyAnywhere(v, path[0])
+			continue
+		}
+		r.maskPath(v, path)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+// maskPath 按路径打码，* 匹配任意key或数组下标
+func (r *redactor) maskPath(v interface{}, path []string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				node[k] = r.mask
+				continue
+			}
+			r.maskPath(child, path[1:])
+		}
+	case []interface{}:
+		for i, child := range node {
+			if path[0] != "*" {
+				// 数组下标需要用 * 匹配，其它key直接作用到每个元素
+				r.maskPath(child, path)
+				continue
+			}
+			if len(path) == 1 {
+				node[i] = r.mask
+				continue
+			}
+			r.maskPath(child, path[1:])
+		}
+	}
+}
+
+// maskKeyAnywhere 在任意层级对指定key打码
+func (r *redactor) maskKeyAnywhere(v interface{}, key string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if k == key {
+				node[k] = r.mask
+				continue
+			}
+			r.maskKeyAnywhere(child, key)
+		}
+	case []interface{}:
+		for _, child := range node {
+			r.maskKeyAnywhere(child, key)
+		}
+	}
+}
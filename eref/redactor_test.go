@@ -0,0 +1,108 @@
+package eref
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRedactorCredentials(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableJWT = true
+	config.JWTTokenLookup = "header:X-Token,query:access_token"
+	config.EnableAPIKey = true
+	config.APIKeyLookup = "header:X-Api-Key,query:api_key"
+	r, err := newRedactor(config)
+	if err != nil {
+		t.Fatalf("newRedactor: %v", err)
+	}
+
+	header := r.Header(http.Header{
+		"X-Api-Key":    {"key-billing"},
+		"X-Signature":  {"sig"},
+		"X-Token":      {"token"},
+		"X-Access-Key": {"billing"},
+	})
+	for _, name := range []string{"X-Api-Key", "X-Signature", "X-Token"} {
+		if got := header.Get(name); got != defaultRedactMask {
+			t.Errorf("header %s = %q, want masked", name, got)
+		}
+	}
+	if got := header.Get("X-Access-Key"); got != "billing" {
+		t.Errorf("header X-Access-Key = %q, want unchanged", got)
+	}
+
+	u, _ := url.Parse("/api/me?b=1&access_token=secret&api%5Fkey=key-billing&a=2")
+	if got, want := r.URI(u), "/api/me?b=1&access_token=******&api%5Fkey=******&a=2"; got != want {
+		t.Errorf("URI = %q, want %q", got, want)
+	}
+	u, _ = url.Parse("/api/me?a=1")
+	if got := r.URI(u); got != "/api/me?a=1" {
+		t.Errorf("URI = %q, want unchanged", got)
+	}
+}
+
+func TestRedactorCredentialsDisabledAuth(t *testing.T) {
+	// 没有开启鉴权时查询参数不是凭证
+	config := DefaultConfig()
+	config.JWTTokenLookup = "query:access_token"
+	r, err := newRedactor(config)
+	if err != nil {
+		t.Fatalf("newRedactor: %v", err)
+	}
+	u, _ := url.Parse("/api/me?access_token=x")
+	if got := r.URI(u); got != "/api/me?access_token=x" {
+		t.Errorf("URI = %q, want unchanged", got)
+	}
+}
+
+func TestRedactorPayload(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessInterceptorRedactFields = []string{"password", "card.number", "items.*.token"}
+	config.AccessInterceptorRedactPatterns = []string{`1[3-9]\d{9}`}
+	config.AccessInterceptorRedactMask = "***"
+	r, err := newRedactor(config)
+	if err != nil {
+		t.Fatalf("newRedactor: %v", err)
+	}
+
+	got := r.Payload([]byte(`{"user":{"password":"p1"},"card":{"number":"6222","bank":"b"},"number":"n","items":[{"token":"t1"},{"token":"t2","id":1}],"phone":"13812345678"}`))
+	want := `{"card":{"bank":"b","number":"***"},"items":[{"token":"***"},{"id":1,"token":"***"}],"number":"n","phone":"***","user":{"password":"***"}}`
+	if got != want {
+		t.Errorf("Payload = %s, want %s", got, want)
+	}
+	// 非JSON报文只做正则替换
+	if got := r.Payload([]byte("phone=13812345678&password=p1")); got != "phone=***&password=p1" {
+		t.Errorf("Payload = %s", got)
+	}
+
+	header := r.Header(http.Header{"Authorization": {"Bearer t"}, "X-Phone": {"13812345678"}, "Accept": {"*/*"}})
+	if header.Get("Authorization") != "***" || header.Get("X-Phone") != "***" || header.Get("Accept") != "*/*" {
+		t.Errorf("Header = %v", header)
+	}
+}
+
+func TestRedactorConfigHeaders(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessInterceptorRedactHeaders = []string{"x-session"}
+	r, err := newRedactor(config)
+	if err != nil {
+		t.Fatalf("newRedactor: %v", err)
+	}
+	original := http.Header{"X-Session": {"s1"}}
+	if got := r.Header(original).Get("X-Session"); got != defaultRedactMask {
+		t.Errorf("X-Session = %q, want masked", got)
+	}
+	// 不修改原header
+	if original.Get("X-Session") != "s1" {
+		t.Error("original header modified")
+	}
+}
+
+func TestRedactorInvalidPattern(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessInterceptorRedactPatterns = []string{"("}
+	if _, err := newRedactor(config); err == nil {
+		t.Error("newRedactor with invalid pattern succeeded")
+	}
+}
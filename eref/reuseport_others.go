@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package eref
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl 当前平台不支持 SO_REUSEPORT
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
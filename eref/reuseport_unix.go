@@ -0,0 +1,19 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package eref
+
+import (
+	"golang.org/x/sys/unix"
+	"syscall"
+)
+
+// reusePortControl 设置 SO_REUSEPORT，多个listener可以监听同一个端口，由内核分配连接
+func reusePortControl(_, _ string, conn syscall.RawConn) error {
+	var err error
+	if ctrlErr := conn.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+	return err
+}
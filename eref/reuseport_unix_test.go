@@ -0,0 +1,48 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package eref_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReusePortListeners(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"Host":               "127.0.0.1",
+		"Port":               0,
+		"EnableReusePort":    true,
+		"ReusePortListeners": 3,
+		"ExtraAddresses":     []string{"127.0.0.1:0"},
+	})
+	pingRoute(s)
+	startServer(t, s)
+	defer func() { _ = s.Component.GracefulStop(context.Background()) }()
+
+	// 每个地址3个listener，同一地址的listener共用端口
+	listeners := s.Component.Listeners()
+	if len(listeners) != 6 {
+		t.Fatalf("got %d listeners, want 6", len(listeners))
+	}
+	primary, extra := listeners[0].Addr().String(), listeners[3].Addr().String()
+	for i, l := range listeners {
+		want := primary
+		if i >= 3 {
+			want = extra
+		}
+		if got := l.Addr().String(); got != want {
+			t.Errorf("listener %d address = %s, want %s", i, got, want)
+		}
+	}
+	if primary == extra {
+		t.Fatalf("primary and extra address are both %s", primary)
+	}
+	for i := 0; i < 10; i++ {
+		expectPong(t, primary)
+		expectPong(t, extra)
+	}
+	// 元数据中的地址去重
+	if got, want := s.Component.Info().Metadata["addresses"], primary+","+extra; got != want {
+		t.Errorf("addresses metadata = %q, want %q", got, want)
+	}
+}
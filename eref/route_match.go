@@ -0,0 +1,39 @@
+package eref
+
+import (
+	"fmt"
+	"path"
+)
+
+// routeMatcher 路由匹配规则集合
+// 规则使用 path.Match 语法，可以只写路由，如 /api/*，也可以带上方法，如 GET./healthz
+type routeMatcher []string
+
+// newRouteMatcher 校验并构建路由匹配规则，name 为配置项名称，用于错误提示
+func newRouteMatcher(name string, patterns []string) (routeMatcher, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s %q, %w", name, pattern, err)
+		}
+	}
+	return routeMatcher(patterns), nil
+}
+
+// Match 是否命中任意一条规则
+func (m routeMatcher) Match(method, routePath string) bool {
+	for _, pattern := range m {
+		if matchRoute(pattern, method, routePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoute 按路由或者 方法.路由 匹配
+func matchRoute(pattern, method, routePath string) bool {
+	if ok, _ := path.Match(pattern, routePath); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, method+"."+routePath)
+	return ok
+}
@@ -0,0 +1,135 @@
+package eref
+
+import (
+	"encoding/json"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultRoutesPath 默认的路由信息路径
+const defaultRoutesPath = "/routes"
+
+// RouteInfo 路由信息
+type RouteInfo struct {
+	Method     string                 `json:"method"`
+	Path       string                 `json:"path"`
+	Comment    string                 `json:"comment,omitempty"`   // 通过 RegisterRouteComment 注册的注释
+	Operation  string                 `json:"operation,omitempty"` // 处理函数名
+	Doc        string                 `json:"doc,omitempty"`
+	Consumes   []string               `json:"consumes,omitempty"`
+	Produces   []string               `json:"produces,omitempty"`
+	Filters    []string               `json:"filters,omitempty"` // 路由级别的 filter 函数名，不包含全局和 WebService 的 filter
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Deprecated bool                   `json:"deprecated,omitempty"`
+}
+
+// routeKey 路由的唯一标识
+type routeKey struct {
+	method string
+	path   string
+}
+
+func newRouteKey(method, path string) routeKey {
+	return routeKey{method: strings.ToUpper(method), path: path}
+}
+
+// routeRegistry 路由注册表，并发安全
+// 路由本身以组件的 restful 容器为准，注册表只保存额外的注释，查询时合并
+type routeRegistry struct {
+	mu       sync.RWMutex
+	comments map[routeKey]string
+}
+
+func newRouteRegistry() *routeRegistry {
+	return &routeRegistry{comments: make(map[routeKey]string)}
+}
+
+// setComment 注册路由注释
+func (r *routeRegistry) setComment(method, path, comment string) {
+	r.mu.Lock()
+	r.comments[newRouteKey(method, path)] = comment
+	r.mu.Unlock()
+}
+
+// comment 返回路由注释
+func (r *routeRegistry) comment(method, path string) (string, bool) {
+	r.mu.RLock()
+	comment, ok := r.comments[newRouteKey(method, path)]
+	r.mu.RUnlock()
+	return comment, ok
+}
+
+// routes 返回所有已注册路由的信息，按路径、方法排序
+func (r *routeRegistry) routes(container *restful.Container) []RouteInfo {
+	var list []RouteInfo
+	for _, ws := range container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			comment, _ := r.comment(route.Method, route.Path)
+			info := RouteInfo{
+				Method:     route.Method,
+				Path:       route.Path,
+				Comment:    comment,
+				Operation:  route.Operation,
+				Doc:        route.Doc,
+				Consumes:   route.Consumes,
+				Produces:   route.Produces,
+				Metadata:   routeMetadata(route.Metadata),
+				Deprecated: route.Deprecated,
+			}
+			for _, filter := range route.Filters {
+				info.Filters = append(info.Filters, funcName(filter))
+			}
+			list = append(list, info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	return list
+}
+
+// routeMetadata 复制路由元数据，忽略无法序列化为JSON的值
+func routeMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if _, err := json.Marshal(v); err == nil {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// funcName 函数的完整名称
+func funcName(f interface{}) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+// Routes 返回所有已注册路由的信息，可以在运行时调用
+func (c *Component) Routes() []RouteInfo {
+	return c.routes.routes(c.container)
+}
+
+// routesHandler 路由信息接口
+func (c *Component) routesHandler(w http.ResponseWriter, _ *http.Request) {
+	data, err := json.Marshal(c.Routes())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", restful.MIME_JSON)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,104 @@
+package eref_test
+
+import (
+	"fmt"
+	"github.com/ego-plugin/server/eref"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// auditFilter 路由级别的 filter，只用于检查路由信息中的 filter 名称
+func auditFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	chain.ProcessFilter(req, resp)
+}
+
+func TestRoutesEndpoint(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"EnableRoutesEndpoint": true})
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.POST("/orders").To(eref.RouteContext(func(ctx eref.Context) {})).
+		Operation("createOrder").
+		Doc("create order").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON, eref.MIME_MSGPACK).
+		Filter(auditFilter).
+		Metadata("owner", "billing").
+		Metadata("handler", func() {}))
+	ws.Route(ws.GET("/orders/{id}").To(eref.RouteContext(func(ctx eref.Context) {})).Deprecate())
+	s.Add(ws)
+	s.Component.RegisterRouteComment("post", "/api/orders", "create order comment")
+
+	var routes []eref.RouteInfo
+	s.GET("/routes").Do().ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", restful.MIME_JSON).
+		DecodeJSON(&routes)
+	var got []string
+	for _, route := range routes {
+		got = append(got, route.Method+" "+route.Path)
+	}
+	// 按路径、方法排序，包含内置接口
+	if want := "POST /api/orders,GET /api/orders/{id},GET /routes/"; strings.Join(got, ",") != want {
+		t.Fatalf("routes = %v, want %s", got, want)
+	}
+	create := routes[0]
+	if create.Comment != "create order comment" || create.Operation != "createOrder" || create.Doc != "create order" {
+		t.Errorf("create route = %+v", create)
+	}
+	if strings.Join(create.Consumes, ",") != restful.MIME_JSON || strings.Join(create.Produces, ",") != restful.MIME_JSON+","+eref.MIME_MSGPACK {
+		t.Errorf("consumes = %v, produces = %v", create.Consumes, create.Produces)
+	}
+	if len(create.Filters) != 1 || !strings.HasSuffix(create.Filters[0], ".auditFilter") {
+		t.Errorf("filters = %v", create.Filters)
+	}
+	// 无法序列化的元数据被忽略
+	if create.Metadata["owner"] != "billing" || len(create.Metadata) != 1 {
+		t.Errorf("metadata = %v", create.Metadata)
+	}
+	if !routes[1].Deprecated || routes[1].Comment != "" {
+		t.Errorf("get route = %+v", routes[1])
+	}
+	// 运行时查询的结果与接口一致
+	if n := len(s.Component.Routes()); n != len(routes) {
+		t.Errorf("Component.Routes returned %d routes, want %d", n, len(routes))
+	}
+}
+
+func TestRoutesEndpointDisabled(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{})
+	s.GET("/routes").Do().ExpectStatus(http.StatusNotFound)
+}
+
+func TestRoutesEndpointBehindAuth(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"EnableRoutesEndpoint": true,
+		"RoutesEndpointPath":   "/debug/routes",
+		"EnableJWT":            true,
+		"JWTSecret":            testJWTSecret,
+	})
+	s.GET("/debug/routes").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+func TestRegisterRouteCommentConcurrent(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{})
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/concurrent").To(eref.RouteContext(func(ctx eref.Context) {})))
+	s.Add(ws)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Component.RegisterRouteComment(http.MethodGet, "/api/concurrent", fmt.Sprintf("comment %d", i))
+				s.Component.Routes()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if comment := s.Component.Routes()[0].Comment; !strings.HasPrefix(comment, "comment ") {
+		t.Errorf("comment = %q", comment)
+	}
+}
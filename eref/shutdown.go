@@ -0,0 +1,176 @@
+package eref
+
+import (
+	"crypto/tls"
+	"github.com/gotomicro/ego/core/elog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// inflightRequest 正在处理的请求
+type inflightRequest struct {
+	method     string
+	path       string
+	remoteAddr string
+	start      time.Time
+}
+
+// connTracker 记录正在处理的请求和被劫持的连接，用于优雅关闭
+// http.Server.Shutdown 不会等待被劫持的连接，例如 websocket
+type connTracker struct {
+	mu       sync.Mutex
+	nextID   uint64
+	requests map[uint64]inflightRequest
+	hijacked map[net.Conn]time.Time
+	idle     chan struct{} // 请求和劫持的连接都结束时关闭
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		requests: make(map[uint64]inflightRequest),
+		hijacked: make(map[net.Conn]time.Time),
+	}
+}
+
+// handler 记录请求的开始和结束
+func (t *connTracker) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		t.nextID++
+		id := t.nextID
+		t.requests[id] = inflightRequest{method: r.Method, path: r.URL.Path, remoteAddr: r.RemoteAddr, start: time.Now()}
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.requests, id)
+			t.notifyIdle()
+			t.mu.Unlock()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// connState 配合 http.Server.ConnState 记录被劫持的连接
+func (t *connTracker) connState(conn net.Conn, state http.ConnState) {
+	if state != http.StateHijacked {
+		return
+	}
+	t.mu.Lock()
+	t.hijacked[rawConn(conn)] = time.Now()
+	t.mu.Unlock()
+}
+
+// listener 包装 listener，感知被劫持的连接关闭，需要在TLS之前包装
+func (t *connTracker) listener(l net.Listener) net.Listener {
+	return &trackedListener{Listener: l, tracker: t}
+}
+
+// closed 连接关闭
+func (t *connTracker) closed(conn net.Conn) {
+	t.mu.Lock()
+	if _, ok := t.hijacked[conn]; ok {
+		delete(t.hijacked, conn)
+		t.notifyIdle()
+	}
+	t.mu.Unlock()
+}
+
+// notifyIdle 没有请求和劫持的连接时唤醒等待方，需要持有锁
+func (t *connTracker) notifyIdle() {
+	if t.idle != nil && len(t.requests) == 0 && len(t.hijacked) == 0 {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// wait 等待请求和劫持的连接全部结束，done 关闭时返回false
+func (t *connTracker) wait(done <-chan struct{}) bool {
+	t.mu.Lock()
+	if len(t.requests) == 0 && len(t.hijacked) == 0 {
+		t.mu.Unlock()
+		return true
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	select {
+	case <-idle:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// logRemaining 打印到期时仍在处理的请求和劫持的连接
+func (t *connTracker) logRemaining(logger *elog.Component) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, req := range t.requests {
+		logger.Warn("request still running at shutdown deadline",
+			elog.FieldMethod(req.method),
+			elog.String("path", req.path),
+			elog.String("peer", req.remoteAddr),
+			elog.Duration("duration", time.Since(req.start)),
+		)
+	}
+	for conn, since := range t.hijacked {
+		logger.Warn("hijacked connection still open at shutdown deadline",
+			elog.String("peer", conn.RemoteAddr().String()),
+			elog.Duration("duration", time.Since(since)),
+		)
+	}
+}
+
+// closeHijacked 强制关闭劫持的连接
+func (t *connTracker) closeHijacked() {
+	t.mu.Lock()
+	conns := make([]net.Conn, 0, len(t.hijacked))
+	for conn := range t.hijacked {
+		conns = append(conns, conn)
+	}
+	t.mu.Unlock()
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+// rawConn TLS连接对应的底层连接
+func rawConn(conn net.Conn) net.Conn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return tlsConn.NetConn()
+	}
+	return conn
+}
+
+type trackedListener struct {
+	net.Listener
+	tracker *connTracker
+}
+
+// Accept implements net.Listener
+func (l *trackedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &trackedConn{Conn: conn, tracker: l.tracker}, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+	once    sync.Once
+}
+
+// Close implements net.Conn
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.tracker.closed(c)
+	})
+	return err
+}
@@ -0,0 +1,160 @@
+package eref_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testClient 不复用连接，避免连接池预先建立的空闲连接让 Shutdown 等待
+var testClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// startServer 监听随机端口并启动服务，返回服务地址
+func startServer(t *testing.T, s *ereftest.Server) string {
+	t.Helper()
+	if err := s.Component.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	go func() {
+		_ = s.Component.Start()
+	}()
+	base := "http://" + s.Component.Listeners()[0].Addr().String()
+	// 等待服务开始处理请求
+	for i := 0; i < 100; i++ {
+		if resp, err := testClient.Get(base + "/livez"); err == nil {
+			_ = resp.Body.Close()
+			return base
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server %s not started", base)
+	return ""
+}
+
+// blockingRoute 进入 handler 时通知 entered，等待 release 关闭或者请求取消后返回
+func blockingRoute(s *ereftest.Server, entered chan<- struct{}, release <-chan struct{}) {
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/slow").To(eref.RouteContext(func(ctx eref.Context) {
+		entered <- struct{}{}
+		select {
+		case <-release:
+			_, _ = ctx.Write([]byte("done"))
+		case <-ctx.Context().Done():
+		}
+	})))
+	s.Add(ws)
+}
+
+func TestGracefulStopWaitsForInflightRequests(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"Host": "127.0.0.1", "Port": 0})
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	blockingRoute(s, entered, release)
+	base := startServer(t, s)
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := testClient.Get(base + "/api/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+	<-entered
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Component.GracefulStop(context.Background())
+	}()
+	select {
+	case err := <-stopped:
+		t.Fatalf("GracefulStop returned %v before the in-flight request finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// 开始关闭后 readiness 失败
+	s.GET("/readyz").Do().ExpectStatus(http.StatusServiceUnavailable)
+
+	close(release)
+	if r := <-responses; r.err != nil || r.body != "done" {
+		t.Fatalf("in-flight response = %q, %v", r.body, r.err)
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("GracefulStop: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GracefulStop did not return after the in-flight request finished")
+	}
+}
+
+func TestGracefulStopDeadline(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"Host": "127.0.0.1", "Port": 0})
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	blockingRoute(s, entered, release)
+	base := startServer(t, s)
+
+	go func() {
+		if resp, err := testClient.Get(base + "/api/slow"); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Component.GracefulStop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GracefulStop = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGracefulStopDrainDelay(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{
+		"Host":               "127.0.0.1",
+		"Port":               0,
+		"ShutdownDrainDelay": "200ms",
+	})
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/ping").To(eref.RouteContext(func(ctx eref.Context) {
+		_, _ = ctx.Write([]byte("pong"))
+	})))
+	s.Add(ws)
+	base := startServer(t, s)
+
+	start := time.Now()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Component.GracefulStop(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// 等待期间 readiness 失败，liveness 正常，仍然可以处理请求
+	s.GET("/readyz").Do().ExpectStatus(http.StatusServiceUnavailable)
+	s.GET("/livez").Do().ExpectStatus(http.StatusOK)
+	resp, err := testClient.Get(base + "/api/ping")
+	if err != nil {
+		t.Fatalf("request during drain delay: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status during drain delay = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("GracefulStop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("GracefulStop returned after %v, want at least the drain delay", elapsed)
+	}
+}
@@ -0,0 +1,245 @@
+package eref
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MIME_EVENT_STREAM = "text/event-stream"
+
+// MetadataStreaming 长连接流式路由，超时中间件、gzip 不处理该路由，通过 Streaming 设置
+// 只根据路由判断，客户端不能通过请求头绕过 ContextTimeout
+const MetadataStreaming = "eref.streaming"
+
+// HeaderLastEventID 客户端重连时携带的最后一个事件ID
+const HeaderLastEventID = "Last-Event-ID"
+
+// sseStreamAttribute 请求的 SSE 流在 restful.Request 中的属性名，请求结束时关闭
+const sseStreamAttribute = "eref.sse"
+
+var (
+	errSSEClosed      = errors.New("sse stream closed")
+	errSSEUnsupported = errors.New("sse streaming unsupported, response writer is not a http.Flusher")
+)
+
+// Streaming 标记路由为流式路由，例如 Server-Sent Events
+func Streaming() func(*restful.RouteBuilder) {
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(MetadataStreaming, true)
+	}
+}
+
+// isStreaming 是否为流式路由，需要在路由匹配之后调用
+func isStreaming(req *restful.Request) bool {
+	if route := req.SelectedRoute(); route != nil {
+		streaming, _ := route.Metadata()[MetadataStreaming].(bool)
+		return streaming
+	}
+	return false
+}
+
+// isEventStream 响应是否为 Server-Sent Events
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get(restful.HEADER_ContentType), MIME_EVENT_STREAM)
+}
+
+// SSEEvent 一条事件，Data 中的换行会拆分为多个 data 字段
+type SSEEvent struct {
+	ID    string        // 事件ID，客户端重连时通过 Last-Event-ID 带回
+	Event string        // 事件类型，为空时客户端按 message 处理
+	Data  string        // 事件数据
+	Retry time.Duration // 客户端重连间隔，为0时不发送
+}
+
+// SSEStream Server-Sent Events 流，并发安全
+// 客户端断开、调用 Close 或者服务 Stop、GracefulStop 时 Done 关闭，handler 应该随之返回
+// Close 返回后不会再写入响应，handler 没有调用 Close 时在 handler 返回时关闭
+type SSEStream struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// SSE 开启 Server-Sent Events 流，写入响应头并立即发送给客户端
+// 按 SSEHeartbeatInterval 发送心跳注释，handler 返回前需要调用 Close
+func (c Context) SSE() (*SSEStream, error) {
+	w := c.Response.ResponseWriter
+	if _, ok := w.(http.Flusher); !ok {
+		return nil, errSSEUnsupported
+	}
+	// 流式响应不受 ServerWriteTimeout 限制
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	lastEventID := c.HeaderParameter(HeaderLastEventID)
+	if lastEventID == "" {
+		// EventSource polyfill 通过查询参数传递
+		lastEventID = c.QueryParameter("lastEventId")
+	}
+	// 通过 restful.Response 写入，访问日志可以记录响应大小
+	s := &SSEStream{
+		w:           c.Response,
+		flusher:     c.Response,
+		lastEventID: lastEventID,
+		done:        make(chan struct{}),
+	}
+	c.SetAttribute(sseStreamAttribute, s)
+	header := c.Response.Header()
+	header.Set(restful.HEADER_ContentType, MIME_EVENT_STREAM)
+	header.Set("Cache-Control", "no-cache")
+	// 关闭 nginx 的响应缓冲
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	c.Response.WriteHeader(http.StatusOK)
+	c.Response.Flush()
+
+	var (
+		heartbeat time.Duration
+		shutdown  <-chan struct{}
+	)
+	if comp := requestComponent(c.Request); comp != nil {
+		heartbeat = comp.config.SSEHeartbeatInterval
+		shutdown = comp.streamsDone
+	}
+	go s.watch(c.Context().Done(), shutdown, heartbeat)
+	return s, nil
+}
+
+// watch 发送心跳，客户端断开或者服务关闭时关闭流
+func (s *SSEStream) watch(canceled, shutdown <-chan struct{}, heartbeat time.Duration) {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			if err := s.Comment("heartbeat"); err != nil {
+				s.Close()
+				return
+			}
+		case <-canceled:
+			s.Close()
+			return
+		case <-shutdown:
+			s.Close()
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// LastEventID 客户端重连时携带的最后一个事件ID，用于断点续传
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done 流关闭时关闭
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 关闭流，可以重复调用，等待正在进行的写入完成后返回
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+// closeLocked 关闭流，需要持有 s.mu
+func (s *SSEStream) closeLocked() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// closeSSEStream 关闭请求的 SSE 流，请求结束后心跳不能再写入响应
+func closeSSEStream(req *restful.Request) {
+	if s, ok := req.Attribute(sseStreamAttribute).(*SSEStream); ok {
+		s.Close()
+	}
+}
+
+// Send 发送事件
+func (s *SSEStream) Send(event SSEEvent) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		writeSSEField(&buf, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeSSEField(&buf, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeSSEField(&buf, "retry", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\n") {
+		writeSSEField(&buf, "data", line)
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// SendData 发送 message 事件
+func (s *SSEStream) SendData(data string) error {
+	return s.Send(SSEEvent{Data: data})
+}
+
+// SendJSON 发送JSON编码的事件
+func (s *SSEStream) SendJSON(id, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Send(SSEEvent{ID: id, Event: event, Data: string(data)})
+}
+
+// Comment 发送注释，客户端会忽略，可以用于保持连接
+func (s *SSEStream) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// write 写入并立即发送，失败时关闭流
+func (s *SSEStream) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return errSSEClosed
+	default:
+	}
+	if _, err := s.w.Write(data); err != nil {
+		s.closeLocked()
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// sseFieldReplacer 字段值中不能包含换行
+var sseFieldReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+func writeSSEField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(sseFieldReplacer.Replace(value))
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,123 @@
+package eref_test
+
+import (
+	"bufio"
+	"context"
+	"github.com/ego-plugin/server/eref"
+	"github.com/ego-plugin/server/eref/ereftest"
+	"github.com/emicklei/go-restful/v3"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSSEEvents(t *testing.T) {
+	s := ereftest.New(t)
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/events").To(eref.RouteContext(func(ctx eref.Context) {
+		stream, err := ctx.SSE()
+		if err != nil {
+			t.Errorf("SSE: %v", err)
+			return
+		}
+		defer stream.Close()
+		_ = stream.Send(eref.SSEEvent{ID: "1", Event: "update", Data: "line1\nline2", Retry: 3 * time.Second})
+		_ = stream.Comment("keep")
+		_ = stream.SendData("last=" + stream.LastEventID())
+	})).Do(eref.Streaming()))
+	s.Add(ws)
+
+	s.GET("/api/events").Header(eref.HeaderLastEventID, "41").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", eref.MIME_EVENT_STREAM).
+		ExpectHeader("Cache-Control", "no-cache").
+		ExpectBody("id: 1\nevent: update\nretry: 3000\ndata: line1\ndata: line2\n\n" +
+			": keep\n\n" +
+			"data: last=41\n\n")
+	// EventSource polyfill 通过查询参数传递
+	s.GET("/api/events").Query("lastEventId", "7").Do().
+		ExpectBodyContains("data: last=7\n\n")
+}
+
+func TestSSEHeartbeatAndClose(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"SSEHeartbeatInterval": "10ms"})
+	streams := make(chan *eref.SSEStream, 1)
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/events").To(eref.RouteContext(func(ctx eref.Context) {
+		stream, err := ctx.SSE()
+		if err != nil {
+			t.Errorf("SSE: %v", err)
+			return
+		}
+		streams <- stream
+		// 不调用 Close，handler 返回时关闭
+		time.Sleep(50 * time.Millisecond)
+	})).Do(eref.Streaming()))
+	s.Add(ws)
+
+	s.GET("/api/events").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains(": heartbeat\n\n")
+	stream := <-streams
+	select {
+	case <-stream.Done():
+	default:
+		t.Fatal("stream not closed after the handler returned")
+	}
+	if err := stream.SendData("late"); err == nil {
+		t.Error("send after the handler returned succeeded")
+	}
+}
+
+func TestSSEGracefulStop(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"Host": "127.0.0.1", "Port": 0})
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/events").To(eref.RouteContext(func(ctx eref.Context) {
+		stream, err := ctx.SSE()
+		if err != nil {
+			t.Errorf("SSE: %v", err)
+			return
+		}
+		_ = stream.SendData("hello")
+		<-stream.Done()
+	})).Do(eref.Streaming()))
+	s.Add(ws)
+	base := startServer(t, s)
+
+	resp, err := testClient.Get(base + "/api/events")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "data: hello\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	// 关闭服务时结束流，不需要等到超时
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Component.GracefulStop(ctx); err != nil {
+		t.Fatalf("GracefulStop: %v", err)
+	}
+}
+
+func TestSSEContextTimeout(t *testing.T) {
+	s := loadServer(t, map[string]interface{}{"ContextTimeout": "20ms"})
+	deadline := func(ctx eref.Context) {
+		_, ok := ctx.Context().Deadline()
+		_, _ = ctx.Write([]byte(strconv.FormatBool(ok)))
+	}
+	ws := eref.NewRoute("/api")
+	ws.Route(ws.GET("/stream").To(eref.RouteContext(deadline)).Produces(eref.MIME_EVENT_STREAM).Do(eref.Streaming()))
+	ws.Route(ws.GET("/plain").To(eref.RouteContext(deadline)).Produces(restful.MIME_JSON, eref.MIME_EVENT_STREAM))
+	s.Add(ws)
+
+	s.GET("/api/stream").Do().ExpectStatus(http.StatusOK).ExpectBody("false")
+	s.GET("/api/plain").Do().ExpectStatus(http.StatusOK).ExpectBody("true")
+	// 客户端不能通过请求头绕过超时
+	s.GET("/api/plain").Header("Accept", eref.MIME_EVENT_STREAM).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("true")
+}
@@ -0,0 +1,52 @@
+package eref
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// newTLSConfig 根据配置构建服务端TLS配置，配置了 TLSClientCAFile 时校验客户端证书
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		return nil, errors.New("invalid tls config, TLSCertFile and TLSKeyFile are required")
+	}
+	cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair fail, %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if config.TLSClientCAFile != "" {
+		data, err := os.ReadFile(config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLSClientCAFile %q, %w", config.TLSClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("invalid TLSClientCAFile %q, no certificate found", config.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	switch config.TLSClientAuth {
+	case "":
+	case "request":
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	case "verify_if_given":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require_and_verify":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid TLSClientAuth %q", config.TLSClientAuth)
+	}
+	if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven && tlsConfig.ClientCAs == nil {
+		return nil, errors.New("invalid tls config, TLSClientCAFile is required to verify client certificates")
+	}
+	return tlsConfig, nil
+}
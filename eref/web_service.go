@@ -29,8 +29,13 @@ func (w *WebService) Value() []*restful.WebService {
 }
 
 func (w *WebService) Build() {
+	w.BuildTo(restful.DefaultContainer)
+}
+
+// BuildTo 注册到指定的容器，例如 Component.Container()
+func (w *WebService) BuildTo(container *restful.Container) {
 	for _, v := range w.v {
-		restful.DefaultContainer.Add(v)
+		container.Add(v)
 	}
 }
 